@@ -0,0 +1,102 @@
+package extapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/network"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
+	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// IGateway is the api of sophon-gateway, it extends the gateway api defined in venus-shared
+// with the methods only provided by sophon-gateway.
+type IGateway interface {
+	v2API.IGateway
+	IAdmin
+	ICluster
+	IProviderExt
+	IChannelExt
+	ITaskServiceProvider
+	ITaskClient
+	IMarketRetrieval
+	IWalletExt
+}
+
+type IAdmin interface {
+	// DisconnectChannel closes the wallet, proof, market or task connection of channelID
+	DisconnectChannel(ctx context.Context, channelID sharedTypes.UUID) error //perm:admin
+	// DisconnectMiner closes all the proof, market and task connections of miner
+	DisconnectMiner(ctx context.Context, miner address.Address) error //perm:admin
+	// DisconnectWallet closes all the connections of wallet account
+	DisconnectWallet(ctx context.Context, account string) error //perm:admin
+	// Drain stops accepting new connections and requests, waits for the outstanding requests,
+	// asks clients to connect to other gateways and then exits
+	Drain(ctx context.Context) error //perm:admin
+}
+
+// IChannelExt lists the connections with the states not included in the gateway api of venus-shared
+type IChannelExt interface {
+	// ListChannelStates returns the states of all the wallet, proof, market and task connections
+	ListChannelStates(ctx context.Context) ([]*types.ChannelState, error) //perm:read
+}
+
+// ICluster is called by other gateway instances of the cluster, the request is handled by this instance only
+type ICluster interface {
+	ClusterComputeProof(ctx context.Context, caller string, miner address.Address, sectorInfos []builtin.ExtendedSectorInfo, rand abi.PoStRandomness, height abi.ChainEpoch, nwVersion network.Version) ([]builtin.PoStProof, error)        //perm:admin
+	ClusterSectorsUnsealPiece(ctx context.Context, caller string, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) (gtypes.UnsealState, error) //perm:admin
+	ClusterWalletHas(ctx context.Context, addr address.Address, accounts []string) (bool, error)                                                                                                                                            //perm:admin
+	ClusterWalletSign(ctx context.Context, addr address.Address, accounts []string, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error)                                                                                     //perm:admin
+}
+
+// IProviderExt is called by proof and market clients to advertise their capacity on registration,
+// the gateway only dispatches requests to the channels with free capacity and matching capabilities
+type IProviderExt interface {
+	IProofProviderExt
+	IMarketProviderExt
+}
+
+type IProofProviderExt interface {
+	ListenProofEventWithCapacity(ctx context.Context, policy *gtypes.ProofRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) //perm:read
+}
+
+type IMarketProviderExt interface {
+	ListenMarketEventWithCapacity(ctx context.Context, policy *gtypes.MarketRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) //perm:read
+}
+
+// ITaskServiceProvider is called by the workers to receive the tasks pushed by services
+type ITaskServiceProvider interface {
+	ListenTaskEvent(ctx context.Context, policy *types.TaskRegisterPolicy) (<-chan *gtypes.RequestEvent, error) //perm:read
+	ResponseTaskEvent(ctx context.Context, resp *gtypes.ResponseEvent) error                                    //perm:read
+}
+
+// ITaskClient is called by services to push the named tasks to the workers of miner and tag
+type ITaskClient interface {
+	PushTask(ctx context.Context, miner address.Address, tag string, name string, payload json.RawMessage) (json.RawMessage, error) //perm:admin
+}
+
+// IMarketRetrieval is called by the market to retrieve the pieces through the market clients of miners,
+// the requests are served by the gateway instance the miner connects to
+type IMarketRetrieval interface {
+	IsUnsealed(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error)                    //perm:admin
+	LocatePiece(ctx context.Context, miner address.Address, pieceCid cid.Cid) ([]types.PieceLocation, error)                                                                                          //perm:admin
+	ReadPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (<-chan *types.PieceChunk, error) //perm:admin
+}
+
+// IWalletExt is called by the services signing through the wallets connected to the gateway
+type IWalletExt interface {
+	// WalletSignMultisig signs msg by each of signers, the failed signers are reported in the results
+	WalletSignMultisig(ctx context.Context, accounts []string, signers []address.Address, msg *sharedTypes.Message) ([]types.SignerSignature, error) //perm:admin
+	// WalletSignBatch signs reqs in batches, the results are in the order of reqs with the errors of the failed requests
+	WalletSignBatch(ctx context.Context, accounts []string, reqs []*gtypes.WalletSignRequest) ([]types.SignerSignature, error) //perm:admin
+}
@@ -0,0 +1,156 @@
+package extapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/network"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
+	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+type IGatewayStruct struct {
+	v2API.IGatewayStruct
+	IAdminStruct
+	IClusterStruct
+	IProviderExtStruct
+	IChannelExtStruct
+	ITaskServiceProviderStruct
+	ITaskClientStruct
+	IMarketRetrievalStruct
+	IWalletExtStruct
+}
+
+type IAdminStruct struct {
+	Internal struct {
+		DisconnectChannel func(ctx context.Context, channelID sharedTypes.UUID) error `perm:"admin"`
+		DisconnectMiner   func(ctx context.Context, miner address.Address) error      `perm:"admin"`
+		DisconnectWallet  func(ctx context.Context, account string) error             `perm:"admin"`
+		Drain             func(ctx context.Context) error                             `perm:"admin"`
+	}
+}
+
+func (s *IAdminStruct) DisconnectChannel(p0 context.Context, p1 sharedTypes.UUID) error {
+	return s.Internal.DisconnectChannel(p0, p1)
+}
+func (s *IAdminStruct) DisconnectMiner(p0 context.Context, p1 address.Address) error {
+	return s.Internal.DisconnectMiner(p0, p1)
+}
+func (s *IAdminStruct) DisconnectWallet(p0 context.Context, p1 string) error {
+	return s.Internal.DisconnectWallet(p0, p1)
+}
+func (s *IAdminStruct) Drain(p0 context.Context) error {
+	return s.Internal.Drain(p0)
+}
+
+type IChannelExtStruct struct {
+	Internal struct {
+		ListChannelStates func(ctx context.Context) ([]*types.ChannelState, error) `perm:"read"`
+	}
+}
+
+func (s *IChannelExtStruct) ListChannelStates(p0 context.Context) ([]*types.ChannelState, error) {
+	return s.Internal.ListChannelStates(p0)
+}
+
+type IClusterStruct struct {
+	Internal struct {
+		ClusterComputeProof       func(ctx context.Context, caller string, miner address.Address, sectorInfos []builtin.ExtendedSectorInfo, rand abi.PoStRandomness, height abi.ChainEpoch, nwVersion network.Version) ([]builtin.PoStProof, error)  `perm:"admin"`
+		ClusterSectorsUnsealPiece func(ctx context.Context, caller string, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) (gtypes.UnsealState, error) `perm:"admin"`
+		ClusterWalletHas          func(ctx context.Context, addr address.Address, accounts []string) (bool, error)                                                                                                                                   `perm:"admin"`
+		ClusterWalletSign         func(ctx context.Context, addr address.Address, accounts []string, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error)                                                                             `perm:"admin"`
+	}
+}
+
+func (s *IClusterStruct) ClusterComputeProof(p0 context.Context, p1 string, p2 address.Address, p3 []builtin.ExtendedSectorInfo, p4 abi.PoStRandomness, p5 abi.ChainEpoch, p6 network.Version) ([]builtin.PoStProof, error) {
+	return s.Internal.ClusterComputeProof(p0, p1, p2, p3, p4, p5, p6)
+}
+func (s *IClusterStruct) ClusterSectorsUnsealPiece(p0 context.Context, p1 string, p2 address.Address, p3 cid.Cid, p4 abi.SectorNumber, p5 sharedTypes.UnpaddedByteIndex, p6 abi.UnpaddedPieceSize, p7 string) (gtypes.UnsealState, error) {
+	return s.Internal.ClusterSectorsUnsealPiece(p0, p1, p2, p3, p4, p5, p6, p7)
+}
+func (s *IClusterStruct) ClusterWalletHas(p0 context.Context, p1 address.Address, p2 []string) (bool, error) {
+	return s.Internal.ClusterWalletHas(p0, p1, p2)
+}
+func (s *IClusterStruct) ClusterWalletSign(p0 context.Context, p1 address.Address, p2 []string, p3 []byte, p4 sharedTypes.MsgMeta) (*crypto.Signature, error) {
+	return s.Internal.ClusterWalletSign(p0, p1, p2, p3, p4)
+}
+
+type IProviderExtStruct struct {
+	Internal struct {
+		ListenProofEventWithCapacity  func(ctx context.Context, policy *gtypes.ProofRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error)  `perm:"read"`
+		ListenMarketEventWithCapacity func(ctx context.Context, policy *gtypes.MarketRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) `perm:"read"`
+	}
+}
+
+func (s *IProviderExtStruct) ListenProofEventWithCapacity(p0 context.Context, p1 *gtypes.ProofRegisterPolicy, p2 *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) {
+	return s.Internal.ListenProofEventWithCapacity(p0, p1, p2)
+}
+func (s *IProviderExtStruct) ListenMarketEventWithCapacity(p0 context.Context, p1 *gtypes.MarketRegisterPolicy, p2 *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) {
+	return s.Internal.ListenMarketEventWithCapacity(p0, p1, p2)
+}
+
+type ITaskServiceProviderStruct struct {
+	Internal struct {
+		ListenTaskEvent   func(ctx context.Context, policy *types.TaskRegisterPolicy) (<-chan *gtypes.RequestEvent, error) `perm:"read"`
+		ResponseTaskEvent func(ctx context.Context, resp *gtypes.ResponseEvent) error                                      `perm:"read"`
+	}
+}
+
+func (s *ITaskServiceProviderStruct) ListenTaskEvent(p0 context.Context, p1 *types.TaskRegisterPolicy) (<-chan *gtypes.RequestEvent, error) {
+	return s.Internal.ListenTaskEvent(p0, p1)
+}
+func (s *ITaskServiceProviderStruct) ResponseTaskEvent(p0 context.Context, p1 *gtypes.ResponseEvent) error {
+	return s.Internal.ResponseTaskEvent(p0, p1)
+}
+
+type ITaskClientStruct struct {
+	Internal struct {
+		PushTask func(ctx context.Context, miner address.Address, tag string, name string, payload json.RawMessage) (json.RawMessage, error) `perm:"admin"`
+	}
+}
+
+func (s *ITaskClientStruct) PushTask(p0 context.Context, p1 address.Address, p2 string, p3 string, p4 json.RawMessage) (json.RawMessage, error) {
+	return s.Internal.PushTask(p0, p1, p2, p3, p4)
+}
+
+type IMarketRetrievalStruct struct {
+	Internal struct {
+		IsUnsealed  func(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error)                     `perm:"admin"`
+		LocatePiece func(ctx context.Context, miner address.Address, pieceCid cid.Cid) ([]types.PieceLocation, error)                                                                                            `perm:"admin"`
+		ReadPiece   func(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (<-chan *types.PieceChunk, error) `perm:"admin"`
+	}
+}
+
+func (s *IMarketRetrievalStruct) IsUnsealed(p0 context.Context, p1 address.Address, p2 cid.Cid, p3 abi.SectorNumber, p4 sharedTypes.UnpaddedByteIndex, p5 abi.UnpaddedPieceSize) (bool, error) {
+	return s.Internal.IsUnsealed(p0, p1, p2, p3, p4, p5)
+}
+func (s *IMarketRetrievalStruct) LocatePiece(p0 context.Context, p1 address.Address, p2 cid.Cid) ([]types.PieceLocation, error) {
+	return s.Internal.LocatePiece(p0, p1, p2)
+}
+func (s *IMarketRetrievalStruct) ReadPiece(p0 context.Context, p1 address.Address, p2 cid.Cid, p3 abi.SectorNumber, p4 sharedTypes.UnpaddedByteIndex, p5 abi.UnpaddedPieceSize) (<-chan *types.PieceChunk, error) {
+	return s.Internal.ReadPiece(p0, p1, p2, p3, p4, p5)
+}
+
+type IWalletExtStruct struct {
+	Internal struct {
+		WalletSignMultisig func(ctx context.Context, accounts []string, signers []address.Address, msg *sharedTypes.Message) ([]types.SignerSignature, error) `perm:"admin"`
+		WalletSignBatch    func(ctx context.Context, accounts []string, reqs []*gtypes.WalletSignRequest) ([]types.SignerSignature, error)                    `perm:"admin"`
+	}
+}
+
+func (s *IWalletExtStruct) WalletSignMultisig(p0 context.Context, p1 []string, p2 []address.Address, p3 *sharedTypes.Message) ([]types.SignerSignature, error) {
+	return s.Internal.WalletSignMultisig(p0, p1, p2, p3)
+}
+func (s *IWalletExtStruct) WalletSignBatch(p0 context.Context, p1 []string, p2 []*gtypes.WalletSignRequest) ([]types.SignerSignature, error) {
+	return s.Internal.WalletSignBatch(p0, p1, p2)
+}
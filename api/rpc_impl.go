@@ -2,6 +2,10 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/ipfs/go-cid"
 
@@ -15,9 +19,15 @@ import (
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
 
+	"github.com/ipfs-force-community/sophon-auth/core"
+
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
+	"github.com/ipfs-force-community/sophon-gateway/cluster"
 	"github.com/ipfs-force-community/sophon-gateway/marketevent"
 	"github.com/ipfs-force-community/sophon-gateway/proofevent"
 	"github.com/ipfs-force-community/sophon-gateway/proxy"
+	"github.com/ipfs-force-community/sophon-gateway/taskevent"
+	"github.com/ipfs-force-community/sophon-gateway/types"
 	"github.com/ipfs-force-community/sophon-gateway/version"
 	"github.com/ipfs-force-community/sophon-gateway/walletevent"
 )
@@ -35,8 +45,10 @@ type IGatewayAPI interface {
 }
 
 var (
-	_ v2API.IGateway = (*GatewayAPIImpl)(nil)
-	_ IGatewayAPI    = (*GatewayAPIImpl)(nil)
+	_ v2API.IGateway     = (*GatewayAPIImpl)(nil)
+	_ extapi.IGateway    = (*GatewayAPIImpl)(nil)
+	_ IGatewayAPI        = (*GatewayAPIImpl)(nil)
+	_ cluster.LocalState = (*GatewayAPIImpl)(nil)
 )
 
 type GatewayAPIImpl struct {
@@ -51,22 +63,42 @@ type GatewayAPIImpl struct {
 	v2API.IMarketServiceProvider
 
 	me *marketevent.MarketEventStream
+
+	te *taskevent.TaskEventStream
+
+	drainCh chan struct{}
+
+	cluster *cluster.Cluster
 }
 
-func NewGatewayAPIImpl(pe *proofevent.ProofEventStream, we *walletevent.WalletEventStream, me *marketevent.MarketEventStream, p proxy.IProxy) *GatewayAPIImpl {
+func NewGatewayAPIImpl(pe *proofevent.ProofEventStream, we *walletevent.WalletEventStream, me *marketevent.MarketEventStream, te *taskevent.TaskEventStream, p proxy.IProxy) *GatewayAPIImpl {
 	return &GatewayAPIImpl{
 		pe:    pe,
 		we:    we,
 		me:    me,
+		te:    te,
 		proxy: p,
 
+		drainCh: make(chan struct{}, 1),
+
 		IProofServiceProvider:  pe,
 		IWalletServiceProvider: we,
 		IMarketServiceProvider: me,
 	}
 }
 
+// SetCluster enables forwarding the requests to other gateway instances if the wallet or miner is not connected to this one
+func (g *GatewayAPIImpl) SetCluster(c *cluster.Cluster) {
+	g.cluster = c
+}
+
 func (g *GatewayAPIImpl) ComputeProof(ctx context.Context, miner address.Address, sectorInfos []builtin.ExtendedSectorInfo, rand abi.PoStRandomness, height abi.ChainEpoch, nwVersion network.Version) ([]builtin.PoStProof, error) {
+	if g.cluster != nil && !g.pe.HasMiner(miner) {
+		caller, _ := core.CtxGetName(ctx)
+		return cluster.Forward(ctx, g.cluster, cluster.KindProof, miner.String(), func(ctx context.Context, api extapi.IGateway) ([]builtin.PoStProof, error) {
+			return api.ClusterComputeProof(ctx, caller, miner, sectorInfos, rand, height, nwVersion)
+		})
+	}
 	return g.pe.ComputeProof(ctx, miner, sectorInfos, rand, height, nwVersion)
 }
 
@@ -79,13 +111,75 @@ func (g *GatewayAPIImpl) ListMinerConnection(ctx context.Context, addr address.A
 }
 
 func (g *GatewayAPIImpl) WalletHas(ctx context.Context, addr address.Address, accounts []string) (bool, error) {
-	return g.we.WalletHas(ctx, addr, accounts)
+	has, err := g.we.WalletHas(ctx, addr, accounts)
+	if err != nil || has || g.cluster == nil {
+		return has, err
+	}
+	// not found in any instance if failed
+	has, _ = cluster.Forward(ctx, g.cluster, cluster.KindWallet, addr.String(), func(ctx context.Context, api extapi.IGateway) (bool, error) {
+		has, err := api.ClusterWalletHas(ctx, addr, accounts)
+		if err == nil && !has {
+			return false, fmt.Errorf("wallet %s not found", addr)
+		}
+		return has, err
+	})
+	return has, nil
 }
 
 func (g *GatewayAPIImpl) WalletSign(ctx context.Context, addr address.Address, accounts []string, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error) {
+	if g.cluster != nil {
+		if has, err := g.we.WalletHas(ctx, addr, accounts); err == nil && !has {
+			return cluster.Forward(ctx, g.cluster, cluster.KindWallet, addr.String(), func(ctx context.Context, api extapi.IGateway) (*crypto.Signature, error) {
+				return api.ClusterWalletSign(ctx, addr, accounts, toSign, meta)
+			})
+		}
+	}
 	return g.we.WalletSign(ctx, addr, accounts, toSign, meta)
 }
 
+func (g *GatewayAPIImpl) WalletSignMultisig(ctx context.Context, accounts []string, signers []address.Address, msg *sharedTypes.Message) ([]types.SignerSignature, error) {
+	// sign by WalletSign to find the signers connected to the other instances of the cluster
+	return walletevent.SignMultisig(ctx, signers, msg, func(ctx context.Context, signer address.Address, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error) {
+		return g.WalletSign(ctx, signer, accounts, toSign, meta)
+	})
+}
+
+func (g *GatewayAPIImpl) WalletSignBatch(ctx context.Context, accounts []string, reqs []*gtypes.WalletSignRequest) ([]types.SignerSignature, error) {
+	if g.cluster == nil || len(reqs) == 0 {
+		return g.we.WalletSignBatch(ctx, accounts, reqs)
+	}
+
+	// the signers connected to the other instances are signed one by one through WalletSign
+	var local []*gtypes.WalletSignRequest
+	var localIndexes []int
+	results := make([]types.SignerSignature, len(reqs))
+	for i, req := range reqs {
+		if has, err := g.we.WalletHas(ctx, req.Signer, accounts); err == nil && !has {
+			results[i].Signer = req.Signer
+			sig, err := g.WalletSign(ctx, req.Signer, accounts, req.ToSign, req.Meta)
+			if err != nil {
+				results[i].Err = err.Error()
+				continue
+			}
+			results[i].Signature = sig
+			continue
+		}
+		local = append(local, req)
+		localIndexes = append(localIndexes, i)
+	}
+	if len(local) == 0 {
+		return results, nil
+	}
+	localResults, err := g.we.WalletSignBatch(ctx, accounts, local)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range localIndexes {
+		results[i] = localResults[j]
+	}
+	return results, nil
+}
+
 func (g *GatewayAPIImpl) ListWalletInfo(ctx context.Context) ([]*gtypes.WalletDetail, error) {
 	return g.we.ListWalletInfo(ctx)
 }
@@ -95,6 +189,12 @@ func (g *GatewayAPIImpl) ListWalletInfoByWallet(ctx context.Context, wallet stri
 }
 
 func (g *GatewayAPIImpl) SectorsUnsealPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) (gtypes.UnsealState, error) {
+	if g.cluster != nil && !g.me.HasMiner(miner) {
+		caller, _ := core.CtxGetName(ctx)
+		return cluster.Forward(ctx, g.cluster, cluster.KindMarket, miner.String(), func(ctx context.Context, api extapi.IGateway) (gtypes.UnsealState, error) {
+			return api.ClusterSectorsUnsealPiece(ctx, caller, miner, pieceCid, sid, offset, size, dest)
+		})
+	}
 	return g.me.SectorsUnsealPiece(ctx, miner, pieceCid, sid, offset, size, dest)
 }
 
@@ -102,6 +202,20 @@ func (g *GatewayAPIImpl) ListMarketConnectionsState(ctx context.Context) ([]gtyp
 	return g.me.ListMarketConnectionsState(ctx)
 }
 
+func (g *GatewayAPIImpl) ListChannelStates(ctx context.Context) ([]*types.ChannelState, error) {
+	var states []*types.ChannelState
+	for _, stream := range []interface {
+		ListChannelStates(context.Context) ([]*types.ChannelState, error)
+	}{g.we, g.pe, g.me, g.te} {
+		s, err := stream.ListChannelStates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s...)
+	}
+	return states, nil
+}
+
 func (g *GatewayAPIImpl) Version(context.Context) (sharedTypes.Version, error) {
 	return sharedTypes.Version{Version: version.UserVersion}, nil
 }
@@ -109,3 +223,150 @@ func (g *GatewayAPIImpl) Version(context.Context) (sharedTypes.Version, error) {
 func (g *GatewayAPIImpl) RegisterReverse(ctx context.Context, hostKey gtypes.HostKey, address string) error {
 	return g.proxy.RegisterReverseByAddr(hostKey, address)
 }
+
+func (g *GatewayAPIImpl) DisconnectChannel(ctx context.Context, channelID sharedTypes.UUID) error {
+	if g.we.DisconnectChannel(ctx, channelID) || g.pe.DisconnectChannel(ctx, channelID) || g.me.DisconnectChannel(ctx, channelID) || g.te.DisconnectChannel(ctx, channelID) {
+		return nil
+	}
+	return fmt.Errorf("channel %s not exit", channelID)
+}
+
+func (g *GatewayAPIImpl) DisconnectMiner(ctx context.Context, miner address.Address) error {
+	proofErr := g.pe.DisconnectMiner(ctx, miner)
+	marketErr := g.me.DisconnectMiner(ctx, miner)
+	taskErr := g.te.DisconnectMiner(ctx, miner)
+	if proofErr != nil && marketErr != nil && taskErr != nil {
+		return fmt.Errorf("miner %s not exit", miner)
+	}
+	return nil
+}
+
+func (g *GatewayAPIImpl) DisconnectWallet(ctx context.Context, account string) error {
+	return g.we.DisconnectWallet(ctx, account)
+}
+
+func (g *GatewayAPIImpl) Drain(ctx context.Context) error {
+	select {
+	case g.drainCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// DrainRequested returns a channel notified when Drain is called
+func (g *GatewayAPIImpl) DrainRequested() <-chan struct{} {
+	return g.drainCh
+}
+
+// DrainStreams drains all the event streams, it returns when the outstanding requests are completed or ctx done
+func (g *GatewayAPIImpl) DrainStreams(ctx context.Context) error {
+	streams := map[string]interface{ Drain(context.Context) error }{
+		"wallet": g.we,
+		"proof":  g.pe,
+		"market": g.me,
+		"task":   g.te,
+	}
+
+	var wg sync.WaitGroup
+	var lk sync.Mutex
+	var errs []string
+	for name, stream := range streams {
+		wg.Add(1)
+		go func(name string, stream interface{ Drain(context.Context) error }) {
+			defer wg.Done()
+			if err := stream.Drain(ctx); err != nil {
+				lk.Lock()
+				errs = append(errs, fmt.Sprintf("drain %s stream: %v", name, err))
+				lk.Unlock()
+			}
+		}(name, stream)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (g *GatewayAPIImpl) ListenProofEventWithCapacity(ctx context.Context, policy *gtypes.ProofRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) {
+	return g.pe.ListenProofEventWithCapacity(ctx, policy, capacity)
+}
+
+func (g *GatewayAPIImpl) ListenMarketEventWithCapacity(ctx context.Context, policy *gtypes.MarketRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) {
+	return g.me.ListenMarketEventWithCapacity(ctx, policy, capacity)
+}
+
+func (g *GatewayAPIImpl) ListenTaskEvent(ctx context.Context, policy *types.TaskRegisterPolicy) (<-chan *gtypes.RequestEvent, error) {
+	return g.te.ListenTaskEvent(ctx, policy)
+}
+
+func (g *GatewayAPIImpl) ResponseTaskEvent(ctx context.Context, resp *gtypes.ResponseEvent) error {
+	return g.te.ResponseTaskEvent(ctx, resp)
+}
+
+func (g *GatewayAPIImpl) PushTask(ctx context.Context, miner address.Address, tag string, name string, payload json.RawMessage) (json.RawMessage, error) {
+	return g.te.PushTask(ctx, miner, tag, name, payload)
+}
+
+func (g *GatewayAPIImpl) IsUnsealed(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error) {
+	return g.me.IsUnsealed(ctx, miner, pieceCid, sid, offset, size)
+}
+
+func (g *GatewayAPIImpl) LocatePiece(ctx context.Context, miner address.Address, pieceCid cid.Cid) ([]types.PieceLocation, error) {
+	return g.me.LocatePiece(ctx, miner, pieceCid)
+}
+
+func (g *GatewayAPIImpl) ReadPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (<-chan *types.PieceChunk, error) {
+	return g.me.ReadPiece(ctx, miner, pieceCid, sid, offset, size)
+}
+
+func (g *GatewayAPIImpl) ClusterComputeProof(ctx context.Context, caller string, miner address.Address, sectorInfos []builtin.ExtendedSectorInfo, rand abi.PoStRandomness, height abi.ChainEpoch, nwVersion network.Version) ([]builtin.PoStProof, error) {
+	return g.pe.ComputeProof(core.CtxWithName(ctx, caller), miner, sectorInfos, rand, height, nwVersion)
+}
+
+func (g *GatewayAPIImpl) ClusterSectorsUnsealPiece(ctx context.Context, caller string, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) (gtypes.UnsealState, error) {
+	return g.me.SectorsUnsealPiece(core.CtxWithName(ctx, caller), miner, pieceCid, sid, offset, size, dest)
+}
+
+func (g *GatewayAPIImpl) ClusterWalletHas(ctx context.Context, addr address.Address, accounts []string) (bool, error) {
+	return g.we.WalletHas(ctx, addr, accounts)
+}
+
+func (g *GatewayAPIImpl) ClusterWalletSign(ctx context.Context, addr address.Address, accounts []string, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error) {
+	return g.we.WalletSign(ctx, addr, accounts, toSign, meta)
+}
+
+// ConnectedKeys returns the wallet addresses and miners connected to this instance
+func (g *GatewayAPIImpl) ConnectedKeys(ctx context.Context) (map[cluster.Kind][]string, error) {
+	keys := make(map[cluster.Kind][]string)
+
+	wallets, err := g.we.ListWalletInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		for _, state := range wallet.ConnectStates {
+			for _, addr := range state.Addrs {
+				keys[cluster.KindWallet] = append(keys[cluster.KindWallet], addr.String())
+			}
+		}
+	}
+
+	proofMiners, err := g.pe.ListConnectedMiners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, miner := range proofMiners {
+		keys[cluster.KindProof] = append(keys[cluster.KindProof], miner.String())
+	}
+
+	marketMiners, err := g.me.ListConnectedMiners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, miner := range marketMiners {
+		keys[cluster.KindMarket] = append(keys[cluster.KindMarket], miner.String())
+	}
+	return keys, nil
+}
@@ -0,0 +1,186 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-jsonrpc"
+	logging "github.com/ipfs/go-log/v2"
+	"go.opencensus.io/tag"
+
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
+	"github.com/ipfs-force-community/sophon-gateway/config"
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+)
+
+var log = logging.Logger("cluster")
+
+// LocalState provides the wallets and miners connected to this instance
+type LocalState interface {
+	ConnectedKeys(ctx context.Context) (map[Kind][]string, error)
+}
+
+// Cluster shares the connections of this instance with other instances by the registry,
+// and forwards the requests to the instance connected with the wallet or miner.
+type Cluster struct {
+	ctx      context.Context
+	self     Instance
+	token    string
+	interval time.Duration
+	registry IRegistry
+
+	clientsLk sync.Mutex
+	clients   map[string]*instanceClient
+}
+
+type instanceClient struct {
+	api    extapi.IGateway
+	closer jsonrpc.ClientCloser
+}
+
+func NewCluster(ctx context.Context, cfg *config.ClusterConfig, registry IRegistry) *Cluster {
+	return &Cluster{
+		ctx:      ctx,
+		self:     Instance{ID: cfg.InstanceID, URL: cfg.URL},
+		token:    cfg.Token,
+		interval: cfg.SyncInterval,
+		registry: registry,
+		clients:  make(map[string]*instanceClient),
+	}
+}
+
+func (c *Cluster) Self() Instance {
+	return c.self
+}
+
+// Start publishes the connections of this instance every sync interval until ctx done
+func (c *Cluster) Start(ctx context.Context, state LocalState) {
+	go func() {
+		if err := c.Sync(ctx, state); err != nil {
+			log.Warnf("sync connections to cluster failed: %v", err)
+		}
+
+		tm := time.NewTicker(c.interval)
+		defer tm.Stop()
+		for {
+			select {
+			case <-tm.C:
+				if err := c.Sync(ctx, state); err != nil {
+					log.Warnf("sync connections to cluster failed: %v", err)
+				}
+				if err := c.evictClients(ctx); err != nil {
+					log.Warnf("evict clients of the instances left failed: %v", err)
+				}
+			case <-ctx.Done():
+				if err := c.registry.Remove(context.Background(), c.self.ID); err != nil {
+					log.Warnf("remove instance %s from cluster failed: %v", c.self.ID, err)
+				}
+				c.closeClients()
+				return
+			}
+		}
+	}()
+}
+
+// Sync publishes the connections of this instance to the registry
+func (c *Cluster) Sync(ctx context.Context, state LocalState) error {
+	keys, err := state.ConnectedKeys(ctx)
+	if err != nil {
+		return err
+	}
+	// keep the records for a few rounds in case of a failed sync
+	ttl := c.interval * 3
+	for _, kind := range []Kind{KindWallet, KindProof, KindMarket} {
+		if err := c.registry.Update(ctx, c.self, kind, keys[kind], ttl); err != nil {
+			return fmt.Errorf("update %s connections: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// Forward calls the instances connected with key of kind one by one, until one of them succeeds
+func Forward[T any](ctx context.Context, c *Cluster, kind Kind, key string, call func(context.Context, extapi.IGateway) (T, error)) (T, error) {
+	var res T
+	instances, err := c.registry.Lookup(ctx, kind, key)
+	if err != nil {
+		return res, fmt.Errorf("lookup instances of %s %s: %w", kind, key, err)
+	}
+
+	var errs []string
+	for _, instance := range instances {
+		if instance.ID == c.self.ID {
+			continue
+		}
+		api, err := c.getClient(instance)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", instance.ID, err))
+			continue
+		}
+
+		tagCtx, _ := tag.New(ctx, tag.Upsert(metrics.ChannelTypeKey, string(kind)))
+		metrics.ClusterForward.Tick(tagCtx)
+		res, err = call(ctx, api)
+		if err == nil {
+			return res, nil
+		}
+		log.Warnf("forward request of %s %s to instance %s failed: %v", kind, key, instance.ID, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", instance.ID, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return res, fmt.Errorf("no gateway instance connected with %s %s", kind, key)
+	}
+	return res, fmt.Errorf("forward request of %s %s failed: %s", kind, key, strings.Join(errs, "; "))
+}
+
+func (c *Cluster) getClient(instance Instance) (extapi.IGateway, error) {
+	c.clientsLk.Lock()
+	defer c.clientsLk.Unlock()
+
+	if client, ok := c.clients[instance.URL]; ok {
+		return client.api, nil
+	}
+	api, closer, err := extapi.DialIGatewayRPC(c.ctx, instance.URL, c.token, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[instance.URL] = &instanceClient{api: api, closer: closer}
+	return api, nil
+}
+
+// evictClients closes the cached clients of the instances removed from the registry or expired
+func (c *Cluster) evictClients(ctx context.Context) error {
+	instances, err := c.registry.Instances(ctx)
+	if err != nil {
+		return err
+	}
+	alive := make(map[string]struct{}, len(instances))
+	for _, instance := range instances {
+		alive[instance.URL] = struct{}{}
+	}
+
+	c.clientsLk.Lock()
+	defer c.clientsLk.Unlock()
+	for url, client := range c.clients {
+		if _, ok := alive[url]; !ok {
+			log.Infof("close client of instance %s left the cluster", url)
+			client.closer()
+			delete(c.clients, url)
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) closeClients() {
+	c.clientsLk.Lock()
+	defer c.clientsLk.Unlock()
+	for url, client := range c.clients {
+		client.closer()
+		delete(c.clients, url)
+	}
+}
@@ -0,0 +1,40 @@
+// stm: #unit
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ipfs-force-community/sophon-gateway/config"
+)
+
+func TestEvictClients(t *testing.T) {
+	ctx := context.Background()
+	registry := NewLocalRegistry()
+	c := NewCluster(ctx, &config.ClusterConfig{InstanceID: "a", URL: "/ip4/127.0.0.1/tcp/45132", SyncInterval: time.Second}, registry)
+	instanceB := Instance{ID: "b", URL: "/ip4/127.0.0.1/tcp/45133"}
+	instanceC := Instance{ID: "c", URL: "/ip4/127.0.0.1/tcp/45134"}
+	require.NoError(t, registry.Update(ctx, instanceB, KindProof, []string{"f01000"}, time.Minute))
+	require.NoError(t, registry.Update(ctx, instanceC, KindProof, []string{"f01000"}, time.Millisecond*100))
+
+	closed := make(map[string]bool)
+	for _, instance := range []Instance{instanceB, instanceC} {
+		url := instance.URL
+		c.clients[url] = &instanceClient{closer: func() { closed[url] = true }}
+	}
+
+	// expired
+	time.Sleep(time.Millisecond * 200)
+	require.NoError(t, c.evictClients(ctx))
+	require.Equal(t, map[string]bool{instanceC.URL: true}, closed)
+	require.Len(t, c.clients, 1)
+
+	// removed
+	require.NoError(t, registry.Remove(ctx, instanceB.ID))
+	require.NoError(t, c.evictClients(ctx))
+	require.True(t, closed[instanceB.URL])
+	require.Empty(t, c.clients)
+}
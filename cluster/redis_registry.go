@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const BackendRedis = "redis"
+
+// redisKeyPrefix the prefix of all the keys written by the registry, the redis may be shared with other services
+const redisKeyPrefix = "sophon-gateway:cluster:"
+
+var kinds = []Kind{KindWallet, KindProof, KindMarket}
+
+var _ IRegistry = (*RedisRegistry)(nil)
+
+// RedisRegistry keeps the records in redis shared by the instances in different processes,
+// the urls of instances are kept in one hash, and the keys of each kind of an instance are kept
+// in a set expired by redis, along with a marker of the instance being alive with the same ttl.
+// The urls are only removed by Remove, the instances crashed are skipped once their records expired.
+type RedisRegistry struct {
+	client *redis.Client
+}
+
+// NewRedisRegistry connects to the redis at addr, eg. 127.0.0.1:6379
+func NewRedisRegistry(ctx context.Context, addr string) (*RedisRegistry, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.WithContext(ctx).Ping().Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("connect to redis %s: %w", addr, err)
+	}
+	return &RedisRegistry{client: client}, nil
+}
+
+func (r *RedisRegistry) Close() error {
+	return r.client.Close()
+}
+
+func instancesKey() string {
+	return redisKeyPrefix + "instances"
+}
+
+func keysKey(instanceID string, kind Kind) string {
+	return fmt.Sprintf("%skeys:%s:%s", redisKeyPrefix, instanceID, kind)
+}
+
+func aliveKey(instanceID string, kind Kind) string {
+	return fmt.Sprintf("%salive:%s:%s", redisKeyPrefix, instanceID, kind)
+}
+
+func (r *RedisRegistry) Update(ctx context.Context, instance Instance, kind Kind, keys []string, ttl time.Duration) error {
+	members := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, key)
+	}
+	_, err := r.client.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(instancesKey(), instance.ID, instance.URL)
+		pipe.Del(keysKey(instance.ID, kind))
+		if len(members) > 0 {
+			pipe.SAdd(keysKey(instance.ID, kind), members...)
+			pipe.PExpire(keysKey(instance.ID, kind), ttl)
+		}
+		pipe.Set(aliveKey(instance.ID, kind), instance.URL, ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisRegistry) Remove(ctx context.Context, instanceID string) error {
+	keys := make([]string, 0, len(kinds)*2)
+	for _, kind := range kinds {
+		keys = append(keys, keysKey(instanceID, kind), aliveKey(instanceID, kind))
+	}
+	_, err := r.client.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(keys...)
+		pipe.HDel(instancesKey(), instanceID)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisRegistry) Lookup(ctx context.Context, kind Kind, key string) ([]Instance, error) {
+	urls, err := r.client.WithContext(ctx).HGetAll(instancesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make(map[string]*redis.BoolCmd, len(urls))
+	_, err = r.client.WithContext(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		for id := range urls {
+			cmds[id] = pipe.SIsMember(keysKey(id, kind), key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for id, cmd := range cmds {
+		if cmd.Val() {
+			instances = append(instances, Instance{ID: id, URL: urls[id]})
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
+
+func (r *RedisRegistry) Instances(ctx context.Context) ([]Instance, error) {
+	urls, err := r.client.WithContext(ctx).HGetAll(instancesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make(map[string]*redis.IntCmd, len(urls))
+	_, err = r.client.WithContext(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		for id := range urls {
+			keys := make([]string, 0, len(kinds))
+			for _, kind := range kinds {
+				keys = append(keys, aliveKey(id, kind))
+			}
+			cmds[id] = pipe.Exists(keys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for id, cmd := range cmds {
+		if cmd.Val() > 0 {
+			instances = append(instances, Instance{ID: id, URL: urls[id]})
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
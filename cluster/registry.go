@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs-force-community/sophon-gateway/config"
+)
+
+type Kind string
+
+const (
+	KindWallet Kind = "wallet"
+	KindProof  Kind = "proof"
+	KindMarket Kind = "market"
+)
+
+const BackendLocal = "local"
+
+// Instance is a gateway instance of the cluster
+type Instance struct {
+	ID string
+	// URL the address for other instances to call this instance
+	URL string
+}
+
+// IRegistry records which wallets and miners are connected to which gateway instance
+type IRegistry interface {
+	// Update replaces the keys of kind connected to instance, the keys expire after ttl if not updated again
+	Update(ctx context.Context, instance Instance, kind Kind, keys []string, ttl time.Duration) error
+	// Remove removes all the records of instance
+	Remove(ctx context.Context, instanceID string) error
+	// Lookup returns the instances connected with key of kind
+	Lookup(ctx context.Context, kind Kind, key string) ([]Instance, error)
+	// Instances returns the instances whose records are not removed or all expired
+	Instances(ctx context.Context) ([]Instance, error)
+}
+
+// NewRegistry creates the registry shared by the instances of the cluster in different processes,
+// the local registry is rejected as it can not be shared across processes
+func NewRegistry(ctx context.Context, cfg *config.ClusterConfig) (IRegistry, error) {
+	switch cfg.Backend {
+	case BackendRedis, "":
+		if len(cfg.Redis) == 0 {
+			return nil, fmt.Errorf("redis address is required by cluster backend %s", BackendRedis)
+		}
+		return NewRedisRegistry(ctx, cfg.Redis)
+	case BackendLocal:
+		return nil, fmt.Errorf("cluster backend %s only shares the connections within one process, a shared backend is required for the cluster mode", BackendLocal)
+	default:
+		return nil, fmt.Errorf("unsupported cluster backend %s", cfg.Backend)
+	}
+}
+
+var _ IRegistry = (*LocalRegistry)(nil)
+
+// LocalRegistry keeps the records in memory, it can only be shared by the instances in the same process, so only used in tests
+type LocalRegistry struct {
+	lk        sync.Mutex
+	instances map[string]*instanceRecord
+}
+
+type instanceRecord struct {
+	instance Instance
+	keys     map[Kind]*keySet
+}
+
+type keySet struct {
+	keys     map[string]struct{}
+	expireAt time.Time
+}
+
+func NewLocalRegistry() *LocalRegistry {
+	return &LocalRegistry{
+		instances: make(map[string]*instanceRecord),
+	}
+}
+
+func (r *LocalRegistry) Update(ctx context.Context, instance Instance, kind Kind, keys []string, ttl time.Duration) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	record, ok := r.instances[instance.ID]
+	if !ok {
+		record = &instanceRecord{keys: make(map[Kind]*keySet)}
+		r.instances[instance.ID] = record
+	}
+	record.instance = instance
+
+	set := &keySet{keys: make(map[string]struct{}, len(keys)), expireAt: time.Now().Add(ttl)}
+	for _, key := range keys {
+		set.keys[key] = struct{}{}
+	}
+	record.keys[kind] = set
+	return nil
+}
+
+func (r *LocalRegistry) Remove(ctx context.Context, instanceID string) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	delete(r.instances, instanceID)
+	return nil
+}
+
+func (r *LocalRegistry) Lookup(ctx context.Context, kind Kind, key string) ([]Instance, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	now := time.Now()
+	var instances []Instance
+	for _, record := range r.instances {
+		set, ok := record.keys[kind]
+		if !ok || now.After(set.expireAt) {
+			continue
+		}
+		if _, ok := set.keys[key]; ok {
+			instances = append(instances, record.instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
+
+func (r *LocalRegistry) Instances(ctx context.Context) ([]Instance, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	now := time.Now()
+	var instances []Instance
+	for _, record := range r.instances {
+		for _, set := range record.keys {
+			if !now.After(set.expireAt) {
+				instances = append(instances, record.instance)
+				break
+			}
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
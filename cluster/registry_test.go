@@ -0,0 +1,93 @@
+// stm: #unit
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ipfs-force-community/sophon-gateway/config"
+)
+
+func TestLocalRegistry(t *testing.T) {
+	testRegistry(t, NewLocalRegistry())
+}
+
+// the redis used by tests is set by SOPHON_GATEWAY_TEST_REDIS, eg. 127.0.0.1:6379, skipped if not set
+func TestRedisRegistry(t *testing.T) {
+	addr := os.Getenv("SOPHON_GATEWAY_TEST_REDIS")
+	if len(addr) == 0 {
+		t.Skip("SOPHON_GATEWAY_TEST_REDIS not set")
+	}
+	registry, err := NewRedisRegistry(context.Background(), addr)
+	require.NoError(t, err)
+	defer registry.Close() // nolint
+	testRegistry(t, registry)
+}
+
+func TestNewRegistry(t *testing.T) {
+	ctx := context.Background()
+	// only shared across processes
+	_, err := NewRegistry(ctx, &config.ClusterConfig{Backend: BackendLocal})
+	require.Error(t, err)
+	_, err = NewRegistry(ctx, &config.ClusterConfig{Backend: "etcd"})
+	require.ErrorContains(t, err, "unsupported cluster backend")
+	_, err = NewRegistry(ctx, &config.ClusterConfig{Backend: BackendRedis})
+	require.ErrorContains(t, err, "redis address is required")
+	// fail at startup if unable to connect
+	_, err = NewRegistry(ctx, &config.ClusterConfig{Backend: BackendRedis, Redis: "127.0.0.1:1"})
+	require.ErrorContains(t, err, "connect to redis")
+}
+
+func testRegistry(t *testing.T, registry IRegistry) {
+	ctx := context.Background()
+	instanceA := Instance{ID: "a", URL: "/ip4/127.0.0.1/tcp/45132"}
+	instanceB := Instance{ID: "b", URL: "/ip4/127.0.0.1/tcp/45133"}
+
+	require.NoError(t, registry.Update(ctx, instanceB, KindProof, []string{"f01000", "f01001"}, time.Minute))
+	require.NoError(t, registry.Update(ctx, instanceA, KindProof, []string{"f01000"}, time.Minute))
+	require.NoError(t, registry.Update(ctx, instanceA, KindWallet, []string{"f1addr"}, time.Millisecond*100))
+
+	instances, err := registry.Lookup(ctx, KindProof, "f01000")
+	require.NoError(t, err)
+	require.Equal(t, []Instance{instanceA, instanceB}, instances)
+
+	instances, err = registry.Lookup(ctx, KindMarket, "f01000")
+	require.NoError(t, err)
+	require.Empty(t, instances)
+
+	// replace the keys
+	require.NoError(t, registry.Update(ctx, instanceB, KindProof, []string{"f01001"}, time.Minute))
+	instances, err = registry.Lookup(ctx, KindProof, "f01000")
+	require.NoError(t, err)
+	require.Equal(t, []Instance{instanceA}, instances)
+
+	// expired
+	instances, err = registry.Lookup(ctx, KindWallet, "f1addr")
+	require.NoError(t, err)
+	require.Equal(t, []Instance{instanceA}, instances)
+	time.Sleep(time.Millisecond * 200)
+	instances, err = registry.Lookup(ctx, KindWallet, "f1addr")
+	require.NoError(t, err)
+	require.Empty(t, instances)
+	instances, err = registry.Instances(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Instance{instanceA, instanceB}, instances)
+
+	require.NoError(t, registry.Remove(ctx, instanceA.ID))
+	instances, err = registry.Lookup(ctx, KindProof, "f01000")
+	require.NoError(t, err)
+	require.Empty(t, instances)
+	instances, err = registry.Instances(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Instance{instanceB}, instances)
+
+	require.NoError(t, registry.Update(ctx, instanceB, KindProof, []string{"f01001"}, time.Millisecond*100))
+	time.Sleep(time.Millisecond * 200)
+	instances, err = registry.Instances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, instances)
+}
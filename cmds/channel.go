@@ -0,0 +1,81 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+var ChannelCmds = &cli.Command{
+	Name:        "channel",
+	Usage:       "channel cmds",
+	Subcommands: []*cli.Command{listChannelCmds},
+}
+
+var listChannelCmds = &cli.Command{
+	Name:  "list",
+	Usage: "list the wallet, proof, market and task connections with the states of circuit breaker and the protocol versions of clients",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "only list the connections of type, wallet, proof, market or task",
+		},
+		&cli.BoolFlag{
+			Name:  "legacy",
+			Usage: "only list the connections of the legacy clients not advertising the protocol version, which need upgrading",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewGatewayClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		states, err := api.ListChannelStates(cctx.Context)
+		if err != nil {
+			return err
+		}
+		filtered := make([]*types.ChannelState, 0, len(states))
+		for _, state := range states {
+			if cctx.IsSet("type") && state.Type != cctx.String("type") {
+				continue
+			}
+			if cctx.Bool("legacy") && state.Version > 0 {
+				continue
+			}
+			filtered = append(filtered, state)
+		}
+		states = filtered
+		statesBytes, err := json.MarshalIndent(states, " ", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(statesBytes))
+		return nil
+	},
+}
+
+// disconnectOwnedChannel disconnects the channel only if it belongs to the owner, matched by owns
+func disconnectOwnedChannel(ctx context.Context, api extapi.IGateway, channelID sharedTypes.UUID, owner string, owns func(state *types.ChannelState) bool) error {
+	states, err := api.ListChannelStates(ctx)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.ChannelID != channelID {
+			continue
+		}
+		if !owns(state) {
+			return fmt.Errorf("channel %s is a %s connection of %s rather than %s", channelID, state.Type, state.Owner, owner)
+		}
+		return api.DisconnectChannel(ctx, channelID)
+	}
+	return fmt.Errorf("channel %s not found", channelID)
+}
@@ -11,14 +11,14 @@ import (
 	"github.com/urfave/cli/v2"
 
 	_ "github.com/filecoin-project/venus/venus-shared/api"
-	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
 
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
 	"github.com/ipfs-force-community/sophon-gateway/config"
 )
 
 const oldRepoPath = "~/.venusgateway"
 
-func NewGatewayClient(ctx *cli.Context) (v2API.IGateway, jsonrpc.ClientCloser, error) {
+func NewGatewayClient(ctx *cli.Context) (extapi.IGateway, jsonrpc.ClientCloser, error) {
 	repoPath, err := homedir.Expand(ctx.String("repo"))
 	if err != nil {
 		return nil, nil, err
@@ -43,7 +43,7 @@ func NewGatewayClient(ctx *cli.Context) (v2API.IGateway, jsonrpc.ClientCloser, e
 		return nil, nil, err
 	}
 
-	return v2API.DialIGatewayRPC(ctx.Context, listen, string(token), nil)
+	return extapi.DialIGatewayRPC(ctx.Context, listen, string(token), nil)
 }
 
 func HasRepo(path string) (bool, error) {
@@ -0,0 +1,26 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var DrainCmd = &cli.Command{
+	Name:  "drain",
+	Usage: "stop accepting new connections and requests, wait for the outstanding requests and then exit the daemon",
+	Flags: []cli.Flag{},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewGatewayClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := api.Drain(cctx.Context); err != nil {
+			return err
+		}
+		fmt.Println("draining, the daemon will exit after the outstanding requests are completed")
+		return nil
+	},
+}
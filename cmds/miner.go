@@ -3,15 +3,19 @@ package cmds
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/filecoin-project/go-address"
+	types "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/urfave/cli/v2"
+
+	gtypes "github.com/ipfs-force-community/sophon-gateway/types"
 )
 
 var MinerCmds = &cli.Command{
 	Name:        "miner",
 	Usage:       "miner cmds",
-	Subcommands: []*cli.Command{listMinerCmds, getMinerStateCmds},
+	Subcommands: []*cli.Command{listMinerCmds, getMinerStateCmds, kickMinerCmds},
 }
 
 var listMinerCmds = &cli.Command{
@@ -62,3 +66,41 @@ var getMinerStateCmds = &cli.Command{
 		return nil
 	},
 }
+
+var kickMinerCmds = &cli.Command{
+	Name:      "kick",
+	Usage:     "disconnect all the proof, market and task connections of miner, or only one of them if channel-id is set",
+	ArgsUsage: "miner-addr",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "channel-id",
+			Usage: "the channel id of the connection to disconnect",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewGatewayClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if cctx.NArg() != 1 {
+			return fmt.Errorf("must specify miner address")
+		}
+		mAddr, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if cctx.IsSet("channel-id") {
+			channelID, err := types.ParseUUID(cctx.String("channel-id"))
+			if err != nil {
+				return err
+			}
+			// the task connections are owned by miner/tag
+			return disconnectOwnedChannel(cctx.Context, api, channelID, mAddr.String(), func(state *gtypes.ChannelState) bool {
+				return state.Type != "wallet" && (state.Owner == mAddr.String() || strings.HasPrefix(state.Owner, mAddr.String()+"/"))
+			})
+		}
+		return api.DisconnectMiner(cctx.Context, mAddr)
+	},
+}
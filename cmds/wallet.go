@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
 	"github.com/urfave/cli/v2"
+
+	gtypes "github.com/ipfs-force-community/sophon-gateway/types"
 )
 
 var WalletCmds = &cli.Command{
 	Name:        "wallet",
 	Usage:       "wallet cmds",
-	Subcommands: []*cli.Command{listWalletCmds, getWalletStateCmds, getWalletByAccountCmds},
+	Subcommands: []*cli.Command{listWalletCmds, getWalletStateCmds, getWalletByAccountCmds, kickWalletCmds},
 }
 
 var listWalletCmds = &cli.Command{
@@ -96,3 +99,37 @@ var getWalletByAccountCmds = &cli.Command{
 		return nil
 	},
 }
+
+var kickWalletCmds = &cli.Command{
+	Name:      "kick",
+	Usage:     "disconnect all the connections of wallet, or only one of them if channel-id is set",
+	ArgsUsage: "wallet-account",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "channel-id",
+			Usage: "the channel id of the connection to disconnect",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewGatewayClient(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if cctx.NArg() != 1 {
+			return fmt.Errorf("must specify wallet account")
+		}
+		account := cctx.Args().Get(0)
+		if cctx.IsSet("channel-id") {
+			channelID, err := sharedTypes.ParseUUID(cctx.String("channel-id"))
+			if err != nil {
+				return err
+			}
+			return disconnectOwnedChannel(cctx.Context, api, channelID, account, func(state *gtypes.ChannelState) bool {
+				return state.Type == "wallet" && state.Owner == account
+			})
+		}
+		return api.DisconnectWallet(cctx.Context, account)
+	},
+}
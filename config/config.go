@@ -2,6 +2,7 @@ package config
 
 import (
 	"io/ioutil"
+	"time"
 
 	"github.com/ipfs-force-community/metrics"
 	"github.com/pelletier/go-toml"
@@ -24,15 +25,99 @@ type Config struct {
 	Metrics   *metrics.MetricsConfig
 	Trace     *metrics.TraceConfig
 	RateLimit *RateLimitCofnig
+	Request   *RequestConfig
+	Market    *MarketConfig
+	Wallet    *WalletConfig
+	Cluster   *ClusterConfig
 }
 
 type APIConfig struct {
 	ListenAddress string
+	// DrainTimeout how long to wait for the outstanding requests before exiting when draining
+	DrainTimeout time.Duration
 }
 
 type AuthConfig struct {
 	URL   string
 	Token string
+	// ValidateCaller check whether the miner of ComputeProof and SectorsUnsealPiece belongs to the caller
+	ValidateCaller bool
+	// RevalidateInterval the interval to verify the token and miner binding of connections again, 0 means disable
+	RevalidateInterval time.Duration
+	// Cache the result of sophon-auth calls
+	Cache *AuthCacheConfig
+}
+
+type AuthCacheConfig struct {
+	Enable bool
+	// TTL how long a successful result is cached
+	TTL time.Duration
+	// NegativeTTL how long a failed result is cached, eg. token is invalid, miner not belongs to user
+	NegativeTTL time.Duration
+	// MaxStale how long an expired result can be still used when sophon-auth is unreachable
+	MaxStale time.Duration
+	// RefreshInterval the interval to refresh the results going to expire in background
+	RefreshInterval time.Duration
+}
+
+type RequestConfig struct {
+	// QueueSize the max requests queued for a connection of the client
+	QueueSize int
+	// EnqueueTimeout how long to wait when the queue of a connection is full before trying other connections, 0 means not to wait
+	EnqueueTimeout time.Duration
+	// Breaker skips the connections failing repeatedly
+	Breaker *BreakerConfig
+	// Heartbeat pings the clients advertising Ping to close the dead connections
+	Heartbeat *HeartbeatConfig
+}
+
+type HeartbeatConfig struct {
+	// Interval the interval to ping the clients, 0 means disable
+	Interval time.Duration
+	// MaxMisses the connection is closed after missing MaxMisses heartbeats in a row
+	MaxMisses int
+}
+
+type BreakerConfig struct {
+	// Window the count of the recent requests to compute the failure rate of a connection, 0 means disable
+	Window int
+	// MinRequests the breaker opens only after at least MinRequests requests in the window
+	MinRequests int
+	// FailureRate the breaker opens when the rate of the failed requests reaches it, eg. the errors responded by the client,
+	// timeout or lost by closed connections
+	FailureRate float64
+	// OpenTimeout how long the connection is skipped before probing it with a request
+	OpenTimeout time.Duration
+}
+
+type MarketConfig struct {
+	// ReadChunkSize the bytes of a piece read from the market client by one request, at most 16MiB
+	ReadChunkSize uint64
+	// MaxReadSize the max bytes of a piece range read by one ReadPiece call, 0 means no limit
+	MaxReadSize uint64
+}
+
+type WalletConfig struct {
+	// Probe asks the connected wallets to list their addresses again when WalletHas or WalletSign misses the address
+	Probe bool
+	// ProbeInterval the min interval to probe the same wallet connection
+	ProbeInterval time.Duration
+}
+
+type ClusterConfig struct {
+	Enable bool
+	// InstanceID the unique id of this instance in the cluster
+	InstanceID string
+	// URL the address for other instances to call this instance, eg. /ip4/192.168.1.10/tcp/45132
+	URL string
+	// Token the token to call other instances, must have admin permission
+	Token string
+	// Backend where to share the connections of instances, only redis is supported
+	Backend string
+	// Redis the address of the redis shared by the instances, eg. 127.0.0.1:6379, required by the redis backend
+	Redis string
+	// SyncInterval the interval to publish the connections of this instance
+	SyncInterval time.Duration
 }
 
 type RateLimitCofnig struct {
@@ -41,11 +126,48 @@ type RateLimitCofnig struct {
 
 func DefaultConfig() *Config {
 	cfg := &Config{
-		API:       &APIConfig{ListenAddress: "/ip4/127.0.0.1/tcp/45132"},
-		Auth:      &AuthConfig{URL: "http://127.0.0.1:8989"},
+		API: &APIConfig{ListenAddress: "/ip4/127.0.0.1/tcp/45132", DrainTimeout: time.Minute},
+		Auth: &AuthConfig{
+			URL:                "http://127.0.0.1:8989",
+			RevalidateInterval: time.Minute * 5,
+			Cache: &AuthCacheConfig{
+				Enable:          true,
+				TTL:             time.Minute,
+				NegativeTTL:     time.Second * 10,
+				MaxStale:        time.Minute * 30,
+				RefreshInterval: time.Second * 30,
+			},
+		},
 		Metrics:   metrics.DefaultMetricsConfig(),
 		Trace:     metrics.DefaultTraceConfig(),
 		RateLimit: &RateLimitCofnig{Redis: ""},
+		Request: &RequestConfig{
+			QueueSize:      30,
+			EnqueueTimeout: time.Second * 5,
+			Breaker: &BreakerConfig{
+				Window:      20,
+				MinRequests: 5,
+				FailureRate: 0.5,
+				OpenTimeout: time.Second * 30,
+			},
+			Heartbeat: &HeartbeatConfig{
+				Interval:  time.Second * 30,
+				MaxMisses: 3,
+			},
+		},
+		Market: &MarketConfig{
+			ReadChunkSize: 1 << 20,
+			MaxReadSize:   1 << 30,
+		},
+		Wallet: &WalletConfig{
+			Probe:         false,
+			ProbeInterval: time.Second * 10,
+		},
+		Cluster: &ClusterConfig{
+			Backend:      "redis",
+			Redis:        "127.0.0.1:6379",
+			SyncInterval: time.Second * 5,
+		},
 	}
 	namespace := "gateway"
 	cfg.Metrics.Exporter.Prometheus.Namespace = namespace
@@ -58,13 +180,15 @@ func DefaultConfig() *Config {
 	return cfg
 }
 
+// ReadConfig reads the config file over the default config, so that the items missing in the file, eg. written by
+// the old versions, keep their default values rather than zero
 func ReadConfig(filePath string) (*Config, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg := &Config{}
+	cfg := DefaultConfig()
 	err = toml.Unmarshal(data, cfg)
 
 	return cfg, err
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,3 +19,30 @@ func TestConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, cfg, res)
 }
+
+func TestReadOldConfig(t *testing.T) {
+	// written by the old versions without the drain timeout and the auth cache
+	data := `
+[API]
+  ListenAddress = "/ip4/0.0.0.0/tcp/45132"
+
+[Auth]
+  URL = "http://127.0.0.1:8989"
+  Token = "token"
+  RevalidateInterval = "0s"
+`
+	cfgPath := filepath.Join(t.TempDir(), ConfigFile)
+	assert.NoError(t, os.WriteFile(cfgPath, []byte(data), 0o644))
+
+	cfg, err := ReadConfig(cfgPath)
+	assert.NoError(t, err)
+	def := DefaultConfig()
+	assert.Equal(t, "/ip4/0.0.0.0/tcp/45132", cfg.API.ListenAddress)
+	assert.Equal(t, time.Minute, cfg.API.DrainTimeout)
+	assert.Equal(t, "token", cfg.Auth.Token)
+	// explicitly disabled
+	assert.Equal(t, time.Duration(0), cfg.Auth.RevalidateInterval)
+	assert.Equal(t, def.Auth.Cache, cfg.Auth.Cache)
+	assert.Equal(t, def.Request, cfg.Request)
+	assert.Equal(t, def.Cluster, cfg.Cluster)
+}
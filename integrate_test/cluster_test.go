@@ -0,0 +1,118 @@
+// stm: #integration
+package integrate
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/network"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+
+	"github.com/ipfs-force-community/sophon-gateway/cluster"
+	"github.com/ipfs-force-community/sophon-gateway/proofevent"
+	"github.com/ipfs-force-community/sophon-gateway/testhelper"
+	"github.com/ipfs-force-community/sophon-gateway/walletevent"
+)
+
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mAddr, err := address.NewIDAddress(10)
+	require.NoError(t, err)
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	require.NoError(t, err)
+	tCfg := defaultTestConfig()
+	tCfg.localSecret = secret
+	tCfg.registry = cluster.NewLocalRegistry()
+
+	// clients connect to instance B, and requests arrive at instance A
+	urlA, tokenA := setupProofDaemon(t, []address.Address{mAddr}, ctx, tCfg)
+	urlB, tokenB := setupProofDaemon(t, []address.Address{mAddr}, ctx, tCfg)
+
+	t.Run("forward proof request", func(t *testing.T) {
+		proofClient, pCloser, err := proofevent.NewProofRegisterClient(ctx, urlB, tokenB)
+		require.NoError(t, err)
+		defer pCloser()
+
+		expectInfo := []builtin.ExtendedSectorInfo{
+			{
+				SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+				SectorNumber: 100,
+				SectorKey:    nil,
+				SealedCID:    cid.Undef,
+			},
+		}
+		expectRand := []byte{1, 23}
+		expectEpoch := abi.ChainEpoch(100)
+		expectVersion := network.Version(10)
+		expectProof := []builtin.PoStProof{
+			{
+				PoStProof:  abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+				ProofBytes: []byte{3, 4},
+			},
+		}
+		handler := testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, expectProof, false)
+		proofEvent := proofevent.NewProofEvent(proofClient, mAddr, handler, logging.Logger("test").With())
+		go proofEvent.ListenProofRequest(ctx)
+		proofEvent.WaitReady(ctx)
+
+		apiA, aCloser, err := serverProofAPI(ctx, urlA, tokenA)
+		require.NoError(t, err)
+		defer aCloser()
+
+		var proof []builtin.PoStProof
+		require.Eventually(t, func() bool {
+			proof, err = apiA.ComputeProof(ctx, mAddr, expectInfo, expectRand, expectEpoch, expectVersion)
+			return err == nil
+		}, time.Second*5, time.Millisecond*100)
+		require.Equal(t, expectProof, proof)
+
+		otherAddr, err := address.NewIDAddress(11)
+		require.NoError(t, err)
+		_, err = apiA.ComputeProof(ctx, otherAddr, expectInfo, expectRand, expectEpoch, expectVersion)
+		require.Contains(t, err.Error(), "no gateway instance connected with proof")
+	})
+
+	t.Run("forward wallet request", func(t *testing.T) {
+		walletClient, wCloser, err := walletevent.NewWalletRegisterClient(ctx, urlB, tokenB)
+		require.NoError(t, err)
+		defer wCloser()
+
+		wallet := testhelper.NewMemWallet()
+		addr, err := wallet.AddKey(ctx)
+		require.NoError(t, err)
+		walletEvent := walletevent.NewWalletEventClient(ctx, wallet, walletClient, logging.Logger("test").With(), getSupportAccountsFunc([]string{"admin"}))
+		go walletEvent.ListenWalletRequest(ctx)
+		walletEvent.WaitReady(ctx)
+
+		apiA, aCloser, err := serverWalletAPI(ctx, urlA, tokenA)
+		require.NoError(t, err)
+		defer aCloser()
+
+		require.Eventually(t, func() bool {
+			has, err := apiA.WalletHas(ctx, addr, []string{"admin"})
+			return err == nil && has
+		}, time.Second*5, time.Millisecond*100)
+
+		msg := []byte("sign by instance B")
+		sig, err := apiA.WalletSign(ctx, addr, []string{"admin"}, msg, types2.MsgMeta{})
+		require.NoError(t, err)
+		require.NoError(t, wallet.Verify(ctx, addr, sig, msg))
+
+		has, err := apiA.WalletHas(ctx, addr, []string{"not_exist"})
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+}
@@ -22,14 +22,16 @@ import (
 	"github.com/ipfs-force-community/sophon-auth/core"
 	"github.com/ipfs-force-community/sophon-auth/jwtclient"
 
-	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
 	"github.com/filecoin-project/venus/venus-shared/api/permission"
 
 	"github.com/ipfs-force-community/sophon-gateway/api"
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
 	"github.com/ipfs-force-community/sophon-gateway/api/v1api"
+	"github.com/ipfs-force-community/sophon-gateway/cluster"
 	"github.com/ipfs-force-community/sophon-gateway/marketevent"
 	metrics2 "github.com/ipfs-force-community/sophon-gateway/metrics"
 	"github.com/ipfs-force-community/sophon-gateway/proofevent"
+	"github.com/ipfs-force-community/sophon-gateway/taskevent"
 	"github.com/ipfs-force-community/sophon-gateway/types"
 	"github.com/ipfs-force-community/sophon-gateway/validator"
 	"github.com/ipfs-force-community/sophon-gateway/validator/mocks"
@@ -42,6 +44,10 @@ var log = logging.Logger("mock main")
 type testConfig struct {
 	requestTimeout time.Duration
 	clearInterval  time.Duration
+
+	// instances in the same cluster should use the same secret to accept the tokens of each other
+	localSecret []byte
+	registry    cluster.IRegistry
 }
 
 func defaultTestConfig() testConfig {
@@ -80,14 +86,16 @@ func MockMain(ctx context.Context, validateMiner []address.Address, repoPath str
 		ClearInterval:    time.Hour,
 	})
 
-	gatewayAPIImpl := api.NewGatewayAPIImpl(proofStream, walletStream, marketStream, nil)
+	taskStream := taskevent.NewTaskEventStream(ctx, minerValidator, requestCfg)
+
+	gatewayAPIImpl := api.NewGatewayAPIImpl(proofStream, walletStream, marketStream, taskStream, nil)
 
 	log.Infof("sophon-gateway current version %s", version.UserVersion)
 	log.Info("Setting up control endpoint at " + cfg.API.ListenAddress)
 
-	var fullNode v2API.IGatewayStruct
+	var fullNode extapi.IGatewayStruct
 	permission.PermissionProxy(gatewayAPIImpl, &fullNode)
-	gatewayAPI := (v2API.IGateway)(&fullNode)
+	gatewayAPI := (extapi.IGateway)(&fullNode)
 
 	if len(cfg.RateLimit.Redis) > 0 {
 		limiter, err := ratelimit.NewRateLimitHandler(cfg.RateLimit.Redis, nil,
@@ -98,7 +106,7 @@ func MockMain(ctx context.Context, validateMiner []address.Address, repoPath str
 		if err != nil {
 			return "", nil, err
 		}
-		var rateLimitAPI v2API.IGatewayStruct
+		var rateLimitAPI extapi.IGatewayStruct
 		limiter.ProxyLimitFullAPI(gatewayAPI, &rateLimitAPI)
 		gatewayAPI = &rateLimitAPI
 	}
@@ -122,7 +130,14 @@ func MockMain(ctx context.Context, validateMiner []address.Address, repoPath str
 
 	mux.PathPrefix("/").Handler(http.DefaultServeMux)
 
-	localJwtCli, localToken, err := jwtclient.NewLocalAuthClient()
+	var localJwtCli *jwtclient.LocalAuthClient
+	var localToken []byte
+	var err error
+	if len(tcfg.localSecret) > 0 {
+		localJwtCli, localToken, err = jwtclient.NewLocalAuthClientWithSecret(tcfg.localSecret)
+	} else {
+		localJwtCli, localToken, err = jwtclient.NewLocalAuthClient()
+	}
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate local jwt client: %v", err)
 	}
@@ -151,5 +166,17 @@ func MockMain(ctx context.Context, validateMiner []address.Address, repoPath str
 	}
 
 	srv := httptest.NewServer(handler)
+
+	if tcfg.registry != nil {
+		gatewayCluster := cluster.NewCluster(ctx, &config.ClusterConfig{
+			Enable:       true,
+			InstanceID:   srv.URL,
+			URL:          srv.URL,
+			Token:        string(localToken),
+			SyncInterval: time.Millisecond * 100,
+		}, tcfg.registry)
+		gatewayCluster.Start(ctx, gatewayAPIImpl)
+		gatewayAPIImpl.SetCluster(gatewayCluster)
+	}
 	return srv.URL, localToken, nil
 }
@@ -0,0 +1,101 @@
+// stm: #integration
+package integrate
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/network"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
+	"github.com/filecoin-project/venus/venus-shared/api"
+
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
+	"github.com/ipfs-force-community/sophon-gateway/proofevent"
+	"github.com/ipfs-force-community/sophon-gateway/testhelper"
+)
+
+func TestMultiGateway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mAddr, err := address.NewIDAddress(10)
+	require.NoError(t, err)
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	require.NoError(t, err)
+	tCfg := defaultTestConfig()
+	tCfg.localSecret = secret
+
+	urlA, token := setupProofDaemon(t, []address.Address{mAddr}, ctx, tCfg)
+	urlB, _ := setupProofDaemon(t, []address.Address{mAddr}, ctx, tCfg)
+
+	_, _, err = proofevent.NewProofRegisterClients(ctx, []string{urlA, "ws://127.0.0.1:1/rpc/v2"}, token)
+	require.Error(t, err)
+
+	clients, cCloser, err := proofevent.NewProofRegisterClients(ctx, []string{urlA, urlB}, token)
+	require.NoError(t, err)
+	defer cCloser()
+
+	expectInfo := []builtin.ExtendedSectorInfo{
+		{
+			SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+			SectorNumber: 100,
+			SectorKey:    nil,
+			SealedCID:    cid.Undef,
+		},
+	}
+	expectRand := []byte{1, 23}
+	expectEpoch := abi.ChainEpoch(100)
+	expectVersion := network.Version(10)
+	expectProof := []builtin.PoStProof{
+		{
+			PoStProof:  abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+			ProofBytes: []byte{3, 4},
+		},
+	}
+	handler := testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, expectProof, false)
+	proofEvent := proofevent.NewMultiProofEvent(clients, mAddr, handler, logging.Logger("test").With())
+	go proofEvent.ListenProofRequest(ctx)
+	proofEvent.WaitReady(ctx)
+
+	headers := http.Header{}
+	headers.Add(api.AuthorizationHeader, "Bearer "+token)
+	apiA, aCloser, err := extapi.NewIGatewayRPC(ctx, urlA, headers)
+	require.NoError(t, err)
+	defer aCloser()
+	apiB, bCloser, err := extapi.NewIGatewayRPC(ctx, urlB, headers)
+	require.NoError(t, err)
+	defer bCloser()
+
+	// registered with both gateways
+	for _, gatewayAPI := range []extapi.IGateway{apiA, apiB} {
+		var proof []builtin.PoStProof
+		require.Eventually(t, func() bool {
+			proof, err = gatewayAPI.ComputeProof(ctx, mAddr, expectInfo, expectRand, expectEpoch, expectVersion)
+			return err == nil
+		}, time.Second*5, time.Millisecond*100)
+		require.Equal(t, expectProof, proof)
+	}
+
+	// still served by gateway B when gateway A lost the connection
+	require.NoError(t, apiA.DisconnectMiner(ctx, mAddr))
+	proof, err := apiB.ComputeProof(ctx, mAddr, expectInfo, expectRand, expectEpoch, expectVersion)
+	require.NoError(t, err)
+	require.Equal(t, expectProof, proof)
+
+	// and reconnect to gateway A later
+	require.Eventually(t, func() bool {
+		_, err := apiA.ComputeProof(ctx, mAddr, expectInfo, expectRand, expectEpoch, expectVersion)
+		return err == nil
+	}, time.Second*10, time.Millisecond*100)
+}
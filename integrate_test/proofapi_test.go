@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -19,8 +20,10 @@ import (
 	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
 
 	"github.com/ipfs-force-community/metrics"
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
 	"github.com/ipfs-force-community/sophon-gateway/config"
 	"github.com/ipfs-force-community/sophon-gateway/testhelper"
+	"github.com/ipfs-force-community/sophon-gateway/types"
 
 	"github.com/ipfs-force-community/sophon-gateway/proofevent"
 
@@ -31,6 +34,7 @@ import (
 	"github.com/filecoin-project/venus/venus-shared/api"
 
 	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
 
 	logging "github.com/ipfs/go-log/v2"
 
@@ -122,6 +126,73 @@ func TestProofAPI(t *testing.T) {
 		}
 	})
 
+	t.Run("reject when client overloaded", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mAddr, err := address.NewIDAddress(10)
+		require.NoError(t, err)
+
+		wsUrl, token := setupProofDaemon(t, []address.Address{mAddr}, ctx, defaultTestConfig())
+		sAPi, sCloser, err := serverProofAPI(ctx, wsUrl, token)
+		require.NoError(t, err)
+		defer sCloser()
+
+		proofClient, cCloser, err := proofevent.NewProofRegisterClient(ctx, wsUrl, token)
+		require.NoError(t, err)
+		defer cCloser()
+
+		proofEvent := proofevent.NewProofEvent(proofClient, mAddr, testhelper.NewTimeoutProofHandler(time.Hour), logging.Logger("test").With())
+		proofEvent.SetWorkerPool(types.NewWorkerPool(types.WorkerPoolConfig{MaxConcurrency: 1}))
+		go proofEvent.ListenProofRequest(ctx)
+		proofEvent.WaitReady(ctx)
+
+		go func() {
+			_, _ = sAPi.ComputeProof(ctx, mAddr, nil, nil, 0, 0)
+		}()
+		require.Eventually(t, func() bool {
+			_, err := sAPi.ComputeProof(ctx, mAddr, nil, nil, 0, 0)
+			return err != nil && strings.Contains(err.Error(), types.ErrOverloaded.Error())
+		}, time.Second*5, time.Millisecond*100)
+	})
+
+	t.Run("respect advertised capacity", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mAddr, err := address.NewIDAddress(10)
+		require.NoError(t, err)
+
+		wsUrl, token := setupProofDaemon(t, []address.Address{mAddr}, ctx, defaultTestConfig())
+		sAPi, sCloser, err := serverProofAPI(ctx, wsUrl, token)
+		require.NoError(t, err)
+		defer sCloser()
+
+		proofClient, cCloser, err := proofevent.NewProofRegisterClient(ctx, wsUrl, token)
+		require.NoError(t, err)
+		defer cCloser()
+
+		proofEvent := proofevent.NewProofEvent(proofClient, mAddr, testhelper.NewTimeoutProofHandler(time.Second), logging.Logger("test").With())
+		proofEvent.SetWorkerPool(types.NewWorkerPool(types.WorkerPoolConfig{MaxConcurrency: 1}))
+		proofEvent.SetCapacity(types.ChannelCapacity{MaxConcurrent: 1})
+		go proofEvent.ListenProofRequest(ctx)
+		proofEvent.WaitReady(ctx)
+
+		errCh := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := sAPi.ComputeProof(ctx, mAddr, nil, nil, 0, 0)
+				errCh <- err
+			}()
+		}
+		require.Eventually(t, func() bool {
+			state, err := sAPi.ListMinerConnection(ctx, mAddr)
+			return err == nil && state.Connections[0].RequestCount == 1
+		}, time.Second*5, time.Millisecond*10)
+
+		// the second request is queued by gateway instead of rejected by the client
+		require.NoError(t, <-errCh)
+		require.NoError(t, <-errCh)
+	})
+
 	t.Run("proof list connect", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -188,6 +259,62 @@ func TestProofAPI(t *testing.T) {
 	})
 }
 
+func TestDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mAddr, err := address.NewIDAddress(10)
+	require.NoError(t, err)
+
+	wsUrl, token := setupProofDaemon(t, []address.Address{mAddr}, ctx, defaultTestConfig())
+	headers := http.Header{}
+	headers.Add(api.AuthorizationHeader, "Bearer "+token)
+	adminAPI, closer, err := extapi.NewIGatewayRPC(ctx, wsUrl, headers)
+	require.NoError(t, err)
+	defer closer()
+
+	listen := func() <-chan *gtypes.RequestEvent {
+		reqCh, err := adminAPI.ListenProofEvent(ctx, &gtypes.ProofRegisterPolicy{MinerAddress: mAddr})
+		require.NoError(t, err)
+		initReq := <-reqCh
+		require.Equal(t, "InitConnect", initReq.Method)
+		return reqCh
+	}
+	// the control events, eg. Protocol, are skipped
+	waitClosed := func(reqCh <-chan *gtypes.RequestEvent) {
+		timeout := time.After(time.Second * 10)
+		for {
+			select {
+			case <-timeout:
+				t.Errorf("unable to wait for closed channel within 10s")
+				return
+			case _, ok := <-reqCh:
+				if !ok {
+					return
+				}
+			}
+		}
+	}
+
+	reqCh := listen()
+	minerState, err := adminAPI.ListMinerConnection(ctx, mAddr)
+	require.NoError(t, err)
+	require.Len(t, minerState.Connections, 1)
+
+	require.NoError(t, adminAPI.DisconnectChannel(ctx, minerState.Connections[0].ChannelID))
+	waitClosed(reqCh)
+	_, err = adminAPI.ListMinerConnection(ctx, mAddr)
+	require.Error(t, err)
+	require.Error(t, adminAPI.DisconnectChannel(ctx, minerState.Connections[0].ChannelID))
+
+	reqCh1, reqCh2 := listen(), listen()
+	require.NoError(t, adminAPI.DisconnectMiner(ctx, mAddr))
+	waitClosed(reqCh1)
+	waitClosed(reqCh2)
+	require.Error(t, adminAPI.DisconnectMiner(ctx, mAddr))
+
+	require.Error(t, adminAPI.DisconnectWallet(ctx, "not_exist"))
+}
+
 func serverProofAPI(ctx context.Context, url, token string) (v2API.IProofEvent, jsonrpc.ClientCloser, error) {
 	headers := http.Header{}
 	headers.Add(api.AuthorizationHeader, "Bearer "+token)
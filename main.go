@@ -32,20 +32,22 @@ import (
 	_ "github.com/filecoin-project/venus/pkg/crypto/bls"
 	_ "github.com/filecoin-project/venus/pkg/crypto/delegated"
 	_ "github.com/filecoin-project/venus/pkg/crypto/secp"
-	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
 	"github.com/filecoin-project/venus/venus-shared/api/permission"
 
 	"github.com/ipfs-force-community/metrics"
 	"github.com/ipfs-force-community/metrics/ratelimit"
 
 	"github.com/ipfs-force-community/sophon-gateway/api"
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
 	"github.com/ipfs-force-community/sophon-gateway/api/v1api"
+	"github.com/ipfs-force-community/sophon-gateway/cluster"
 	"github.com/ipfs-force-community/sophon-gateway/cmds"
 	"github.com/ipfs-force-community/sophon-gateway/config"
 	"github.com/ipfs-force-community/sophon-gateway/marketevent"
 	metrics2 "github.com/ipfs-force-community/sophon-gateway/metrics"
 	"github.com/ipfs-force-community/sophon-gateway/proofevent"
 	"github.com/ipfs-force-community/sophon-gateway/proxy"
+	"github.com/ipfs-force-community/sophon-gateway/taskevent"
 	"github.com/ipfs-force-community/sophon-gateway/types"
 	"github.com/ipfs-force-community/sophon-gateway/validator"
 	"github.com/ipfs-force-community/sophon-gateway/version"
@@ -78,7 +80,7 @@ func main() {
 			},
 		},
 		Commands: []*cli.Command{
-			runCmd, cmds.MinerCmds, cmds.WalletCmds, cmds.MarketCmds, cmds.ProxyCmds,
+			runCmd, cmds.MinerCmds, cmds.WalletCmds, cmds.MarketCmds, cmds.ChannelCmds, cmds.ProxyCmds, cmds.DrainCmd,
 		},
 	}
 	app.Version = version.UserVersion
@@ -94,6 +96,7 @@ var runCmd = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "auth-url", Usage: "sophon auth url"},
 		&cli.StringFlag{Name: "auth-token", Usage: "sophon auth token"},
+		&cli.BoolFlag{Name: "validate-caller", Usage: "check whether the miner of proof and unseal request belongs to the caller"},
 		&cli.StringFlag{Name: "jaeger-proxy", EnvVars: []string{"SOPHON_GATEWAY_JAEGER_PROXY"}},
 		&cli.Float64Flag{Name: "trace-sampler", EnvVars: []string{"SOPHON_GATEWAY_TRACE_SAMPLER"}, Value: 1.0},
 		&cli.StringFlag{Name: "trace-node-name", Value: "sophon-gateway"},
@@ -150,6 +153,9 @@ func parseFlag(cctx *cli.Context, cfg *config.Config) {
 	if cctx.IsSet("auth-token") {
 		cfg.Auth.Token = cctx.String("auth-token")
 	}
+	if cctx.IsSet("validate-caller") {
+		cfg.Auth.ValidateCaller = cctx.Bool("validate-caller")
+	}
 	if cctx.IsSet("jaeger-proxy") {
 		cfg.Trace.JaegerEndpoint = strings.TrimSpace(cctx.String("jaeger-proxy"))
 		cfg.Trace.JaegerTracingEnabled = true
@@ -167,33 +173,101 @@ func parseFlag(cctx *cli.Context, cfg *config.Config) {
 
 func RunMain(ctx context.Context, repoPath string, cfg *config.Config) error {
 	requestCfg := types.DefaultConfig()
+	requestCfg.ValidateCaller = cfg.Auth.ValidateCaller
+	if cfg.Wallet != nil {
+		requestCfg.WalletProbe = cfg.Wallet.Probe
+		requestCfg.WalletProbeInterval = cfg.Wallet.ProbeInterval
+	}
+	marketRequestCfg := &types.RequestConfig{
+		RequestQueueSize: 30,
+		RequestTimeout:   time.Hour * 7, // wait seven hour to do unseal
+		ClearInterval:    time.Minute * 5,
+		ValidateCaller:   cfg.Auth.ValidateCaller,
+		EnqueueTimeout:   requestCfg.EnqueueTimeout,
+		PieceChunkSize:   requestCfg.PieceChunkSize,
+		MaxPieceReadSize: requestCfg.MaxPieceReadSize,
+		Breaker:          requestCfg.Breaker,
+		Heartbeat:        requestCfg.Heartbeat,
+	}
+	if cfg.Market != nil {
+		if cfg.Market.ReadChunkSize > 0 {
+			marketRequestCfg.PieceChunkSize = cfg.Market.ReadChunkSize
+		}
+		marketRequestCfg.MaxPieceReadSize = cfg.Market.MaxReadSize
+	}
+	if cfg.Request != nil {
+		if cfg.Request.QueueSize > 0 {
+			requestCfg.RequestQueueSize = cfg.Request.QueueSize
+			marketRequestCfg.RequestQueueSize = cfg.Request.QueueSize
+		}
+		requestCfg.EnqueueTimeout = cfg.Request.EnqueueTimeout
+		marketRequestCfg.EnqueueTimeout = cfg.Request.EnqueueTimeout
+		if cfg.Request.Breaker != nil {
+			requestCfg.Breaker = types.BreakerConfig{
+				Window:      cfg.Request.Breaker.Window,
+				MinRequests: cfg.Request.Breaker.MinRequests,
+				FailureRate: cfg.Request.Breaker.FailureRate,
+				OpenTimeout: cfg.Request.Breaker.OpenTimeout,
+			}
+			marketRequestCfg.Breaker = requestCfg.Breaker
+		}
+		if cfg.Request.Heartbeat != nil {
+			requestCfg.Heartbeat = types.HeartbeatConfig{
+				Interval:  cfg.Request.Heartbeat.Interval,
+				MaxMisses: cfg.Request.Heartbeat.MaxMisses,
+			}
+			marketRequestCfg.Heartbeat = requestCfg.Heartbeat
+		}
+	}
 
 	remoteJwtCli, err := jwtclient.NewAuthClient(cfg.Auth.URL, cfg.Auth.Token)
 	if err != nil {
 		return err
 	}
 
-	minerValidator := validator.NewMinerValidator(remoteJwtCli)
+	var authClient jwtclient.IAuthClient = remoteJwtCli
+	if cfg.Auth.Cache != nil && cfg.Auth.Cache.Enable {
+		authClient = validator.NewCachedAuthClient(ctx, remoteJwtCli, cfg.Auth.Cache)
+	}
+
+	minerValidator := validator.NewMinerValidator(authClient)
 
-	walletStream := walletevent.NewWalletEventStream(ctx, remoteJwtCli, requestCfg)
+	walletStream := walletevent.NewWalletEventStream(ctx, authClient, requestCfg)
 
 	proofStream := proofevent.NewProofEventStream(ctx, minerValidator, requestCfg)
-	marketStream := marketevent.NewMarketEventStream(ctx, minerValidator, &types.RequestConfig{
-		RequestQueueSize: 30,
-		RequestTimeout:   time.Hour * 7, // wait seven hour to do unseal
-		ClearInterval:    time.Minute * 5,
-	})
+	marketStream := marketevent.NewMarketEventStream(ctx, minerValidator, marketRequestCfg)
+	taskStream := taskevent.NewTaskEventStream(ctx, minerValidator, requestCfg)
 
 	chainServiceProxy := proxy.NewProxy()
 
-	gatewayAPIImpl := api.NewGatewayAPIImpl(proofStream, walletStream, marketStream, chainServiceProxy)
+	gatewayAPIImpl := api.NewGatewayAPIImpl(proofStream, walletStream, marketStream, taskStream, chainServiceProxy)
+
+	if cfg.Cluster != nil && cfg.Cluster.Enable {
+		if len(cfg.Cluster.Token) == 0 {
+			return fmt.Errorf("token is required to call other instances of the cluster")
+		}
+		if len(cfg.Cluster.URL) == 0 {
+			cfg.Cluster.URL = cfg.API.ListenAddress
+		}
+		if len(cfg.Cluster.InstanceID) == 0 {
+			cfg.Cluster.InstanceID = cfg.Cluster.URL
+		}
+		registry, err := cluster.NewRegistry(ctx, cfg.Cluster)
+		if err != nil {
+			return err
+		}
+		gatewayCluster := cluster.NewCluster(ctx, cfg.Cluster, registry)
+		gatewayCluster.Start(ctx, gatewayAPIImpl)
+		gatewayAPIImpl.SetCluster(gatewayCluster)
+		log.Infof("join cluster as instance %s", cfg.Cluster.InstanceID)
+	}
 
 	log.Infof("sophon-gateway current version %s", version.UserVersion)
 	log.Infof("Setting up control endpoint at %v", cfg.API.ListenAddress)
 
-	var fullNode v2API.IGatewayStruct
+	var fullNode extapi.IGatewayStruct
 	permission.PermissionProxy(gatewayAPIImpl, &fullNode)
-	gatewayAPI := (v2API.IGateway)(&fullNode)
+	gatewayAPI := (extapi.IGateway)(&fullNode)
 
 	if len(cfg.RateLimit.Redis) > 0 {
 		limiter, err := ratelimit.NewRateLimitHandler(cfg.RateLimit.Redis, nil,
@@ -204,7 +278,7 @@ func RunMain(ctx context.Context, repoPath string, cfg *config.Config) error {
 		if err != nil {
 			return err
 		}
-		var rateLimitAPI v2API.IGatewayStruct
+		var rateLimitAPI extapi.IGatewayStruct
 		limiter.ProxyLimitFullAPI(gatewayAPI, &rateLimitAPI)
 		gatewayAPI = &rateLimitAPI
 	}
@@ -233,14 +307,22 @@ func RunMain(ctx context.Context, repoPath string, cfg *config.Config) error {
 		return fmt.Errorf("failed to save local token to token file: %w", err)
 	}
 
-	authMux := jwtclient.NewAuthMux(localJwtCli, jwtclient.WarpIJwtAuthClient(remoteJwtCli), mux)
+	if cfg.Auth.RevalidateInterval > 0 {
+		tokenValidator := validator.NewTokenValidator(localJwtCli, jwtclient.WarpIJwtAuthClient(authClient))
+		walletStream.StartRevalidate(ctx, tokenValidator, cfg.Auth.RevalidateInterval)
+		proofStream.StartRevalidate(ctx, tokenValidator, cfg.Auth.RevalidateInterval)
+		marketStream.StartRevalidate(ctx, tokenValidator, cfg.Auth.RevalidateInterval)
+		taskStream.StartRevalidate(ctx, tokenValidator, cfg.Auth.RevalidateInterval)
+	}
+
+	authMux := jwtclient.NewAuthMux(localJwtCli, jwtclient.WarpIJwtAuthClient(authClient), mux)
 	authMux.TrustHandle("/debug/pprof/", http.DefaultServeMux)
 	authMux.TrustHandle("/healthcheck", healthcheck.Handler())
 
 	if err := metrics2.SetupMetrics(ctx, cfg.Metrics, gatewayAPIImpl); err != nil {
 		return err
 	}
-	handler := (http.Handler)(authMux)
+	handler := validator.TokenHandler(authMux)
 	if cfg.Trace.JaegerTracingEnabled {
 		log.Infof("trace config %+v", cfg.Trace)
 		reporter, err := metrics.SetupJaegerTracing(cfg.Trace.ServerName, cfg.Trace)
@@ -299,11 +381,32 @@ func RunMain(ctx context.Context, repoPath string, cfg *config.Config) error {
 	sigCh := make(chan os.Signal, 2)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
+		drain := true
 		select {
 		case sig := <-sigCh:
 			log.Warnw("received shutdown", "signal", sig)
+		case <-gatewayAPIImpl.DrainRequested():
+			log.Warn("received drain request")
 		case <-ctx.Done():
 			log.Warn("received shutdown")
+			drain = false
+		}
+
+		if drain {
+			log.Infof("Draining, wait for outstanding requests up to %s...", cfg.API.DrainTimeout)
+			drainCtx, cancel := context.WithTimeout(context.Background(), cfg.API.DrainTimeout)
+			go func() {
+				select {
+				case sig := <-sigCh:
+					log.Warnw("received shutdown again, stop draining", "signal", sig)
+					cancel()
+				case <-drainCtx.Done():
+				}
+			}()
+			if err := gatewayAPIImpl.DrainStreams(drainCtx); err != nil {
+				log.Warnf("drain not completed: %s", err)
+			}
+			cancel()
 		}
 
 		log.Info("Shutting down...")
@@ -2,10 +2,9 @@ package marketevent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"time"
 
 	"go.uber.org/zap"
 
@@ -14,9 +13,9 @@ import (
 
 	"github.com/filecoin-project/venus/venus-shared/api"
 	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
-	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/filecoin-project/venus/venus-shared/types/gateway"
 
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
 	"github.com/ipfs-force-community/sophon-gateway/types"
 )
 
@@ -25,13 +24,14 @@ type MarketEvent struct {
 	mAddr         address.Address
 	marketHandler types.MarketHandler
 	log           *zap.SugaredLogger
-	readyCh       chan struct{}
+	capacity      *types.ChannelCapacity
+	*types.EventClient
 }
 
 func NewMarketRegisterClient(ctx context.Context, url, token string) (v2API.IMarketServiceProvider, jsonrpc.ClientCloser, error) {
 	headers := http.Header{}
 	headers.Add(api.AuthorizationHeader, "Bearer "+token)
-	client, closer, err := v2API.NewIGatewayRPC(ctx, url, headers)
+	client, closer, err := extapi.NewIGatewayRPC(ctx, url, headers)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -39,113 +39,83 @@ func NewMarketRegisterClient(ctx context.Context, url, token string) (v2API.IMar
 }
 
 func NewMarketEventClient(client v2API.IMarketServiceProvider, mAddr address.Address, marketHandler types.MarketHandler, log *zap.SugaredLogger) *MarketEvent {
-	return &MarketEvent{
+	e := &MarketEvent{
 		client:        client,
 		mAddr:         mAddr,
 		marketHandler: marketHandler,
 		log:           log,
-		readyCh:       make(chan struct{}, 1),
+		EventClient:   types.NewEventClient(log, client.ResponseMarketEvent),
 	}
+	e.Handle("SectorsUnsealPiece", types.TypedHandler(e.processSectorsUnsealPiece))
+	if retrieval, ok := marketHandler.(types.MarketRetrievalHandler); ok {
+		e.Handle("IsUnsealed", types.TypedHandler(func(ctx context.Context, req types.PieceRangeRequest) (bool, error) {
+			return retrieval.IsUnsealed(ctx, req.Miner, req.PieceCid, req.Sid, req.Offset, req.Size)
+		}))
+		e.Handle("LocatePiece", types.TypedHandler(func(ctx context.Context, req types.LocatePieceRequest) ([]types.PieceLocation, error) {
+			return retrieval.LocatePiece(ctx, req.Miner, req.PieceCid)
+		}))
+		e.Handle("ReadPiece", types.TypedHandler(func(ctx context.Context, req types.PieceRangeRequest) ([]byte, error) {
+			return readPiece(ctx, retrieval, req)
+		}))
+	}
+	return e
 }
 
-func (e *MarketEvent) WaitReady(ctx context.Context) {
-	select {
-	case <-e.readyCh:
-	case <-ctx.Done():
-	}
+// SetCapacity advertises the max concurrent requests and the capabilities to gateway on registration,
+// it requires the gateway to support the extension api of sophon-gateway, must be called before listening
+func (e *MarketEvent) SetCapacity(capacity types.ChannelCapacity) {
+	e.capacity = &capacity
 }
 
-func (e *MarketEvent) ListenMarketRequest(ctx context.Context) {
+func (e *MarketEvent) ListenMarketRequest(ctx context.Context) error {
 	e.log.Infof("start market event listening")
-	for {
-		if err := e.listenMarketRequestOnce(ctx); err != nil {
-			e.log.Errorf("listen market request errored: %s", err)
-		} else {
-			e.log.Warn("list market request quit")
-		}
-		select {
-		case <-time.After(time.Second):
-		case <-ctx.Done():
-			e.log.Warnf("not restarting listen market event: context error: %s", ctx.Err())
-			return
-		}
-
-		e.log.Info("restarting listen market event ")
-		// try clear ready channel
-		select {
-		case <-e.readyCh:
-		default:
-		}
-	}
+	return e.Run(ctx, e.listen)
 }
 
 func (e *MarketEvent) listenMarketRequestOnce(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	policy := &gateway.MarketRegisterPolicy{
-		Miner: e.mAddr,
+	return e.ListenOnce(ctx, e.listen)
+}
+
+func (e *MarketEvent) processSectorsUnsealPiece(ctx context.Context, req gateway.UnsealRequest) (interface{}, error) {
+	return nil, e.marketHandler.SectorsUnsealPiece(ctx, req.Miner, req.PieceCid, req.Sid, req.Offset, req.Size, req.Dest)
+}
+
+// readPiece reads exactly the range of req, which is at most MaxPieceChunkSize bytes
+func readPiece(ctx context.Context, retrieval types.MarketRetrievalHandler, req types.PieceRangeRequest) ([]byte, error) {
+	if req.Size > types.MaxPieceChunkSize {
+		return nil, fmt.Errorf("read size %d exceeds the chunk limit %d", req.Size, types.MaxPieceChunkSize)
 	}
-	marketEventCh, err := e.client.ListenMarketEvent(ctx, policy)
+	r, err := retrieval.ReadPiece(ctx, req.Miner, req.PieceCid, req.Sid, req.Offset, req.Size)
 	if err != nil {
-		// Retry is handled by caller
-		return fmt.Errorf("listen market event call failed: %w", err)
+		return nil, err
 	}
+	defer r.Close() // nolint: errcheck
 
-	for marketEvent := range marketEventCh {
-		switch marketEvent.Method {
-		case "InitConnect":
-			req := gateway.ConnectedCompleted{}
-			err := json.Unmarshal(marketEvent.Payload, &req)
-			if err != nil {
-				return fmt.Errorf("odd error in connect %v", err)
-			}
-			e.readyCh <- struct{}{}
-			e.log.Infof("success to connect with market %s", req.ChannelId)
-		case "SectorsUnsealPiece":
-			req := gateway.UnsealRequest{}
-			err := json.Unmarshal(marketEvent.Payload, &req)
-			if err != nil {
-				e.error(ctx, marketEvent.ID, err)
-				continue
-			}
-			err = e.marketHandler.SectorsUnsealPiece(ctx, req.Miner, req.PieceCid, req.Sid, req.Offset, req.Size, req.Dest)
-			if err != nil {
-				e.error(ctx, marketEvent.ID, err)
-				continue
-			}
-			e.value(ctx, marketEvent.ID, nil)
-		default:
-			e.log.Errorf("unexpect market event type %s", marketEvent.Method)
-		}
+	data := make([]byte, req.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("read %d bytes at offset %d of piece %s: %w", req.Size, req.Offset, req.PieceCid, err)
 	}
-
-	return nil
+	return data, nil
 }
 
-func (e *MarketEvent) value(ctx context.Context, id sharedTypes.UUID, val interface{}) {
-	respBytes, err := json.Marshal(val)
-	if err != nil {
-		e.log.Errorf("marshal address list error %s", err)
-		e.error(ctx, id, err)
-		return
+func (e *MarketEvent) listen(ctx context.Context) (<-chan *gateway.RequestEvent, error) {
+	policy := &gateway.MarketRegisterPolicy{
+		Miner: e.mAddr,
 	}
-	err = e.client.ResponseMarketEvent(ctx, &gateway.ResponseEvent{
-		ID:      id,
-		Payload: respBytes,
-		Error:   "",
-	})
+	ch, err := e.register(ctx, policy)
 	if err != nil {
-		e.log.Errorf("response error %v", err)
+		return nil, fmt.Errorf("listen market event call failed: %w", err)
 	}
+	return ch, nil
 }
 
-func (e *MarketEvent) error(ctx context.Context, id sharedTypes.UUID, err error) {
-	err = e.client.ResponseMarketEvent(ctx, &gateway.ResponseEvent{
-		ID:      id,
-		Payload: nil,
-		Error:   err.Error(),
-	})
-	if err != nil {
-		e.log.Errorf("response error %v", err)
+func (e *MarketEvent) register(ctx context.Context, policy *gateway.MarketRegisterPolicy) (<-chan *gateway.RequestEvent, error) {
+	if e.capacity == nil {
+		return e.client.ListenMarketEvent(ctx, policy)
+	}
+	client, ok := e.client.(extapi.IMarketProviderExt)
+	if !ok {
+		return nil, fmt.Errorf("client is unable to advertise capacity")
 	}
+	return client.ListenMarketEventWithCapacity(ctx, policy, e.capacity)
 }
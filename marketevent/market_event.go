@@ -2,9 +2,7 @@ package marketevent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"sync"
 	"time"
 
 	"go.opencensus.io/stats"
@@ -31,142 +29,215 @@ var log = logging.Logger("market_stream")
 var _ v2API.IMarketClient = (*MarketEventStream)(nil)
 
 type MarketEventStream struct {
-	connLk           sync.RWMutex
-	minerConnections map[address.Address]*channelStore
-	cfg              *types.RequestConfig
-	validator        validator.IAuthMinerValidator
-	*types.BaseEventStream
+	cfg       *types.RequestConfig
+	validator validator.IAuthMinerValidator
+	*types.EventService[address.Address]
 }
 
-func NewMarketEventStream(ctx context.Context, validator validator.IAuthMinerValidator, cfg *types.RequestConfig) *MarketEventStream {
+func NewMarketEventStream(ctx context.Context, minerValidator validator.IAuthMinerValidator, cfg *types.RequestConfig) *MarketEventStream {
 	marketEventStream := &MarketEventStream{
-		connLk:           sync.RWMutex{},
-		minerConnections: make(map[address.Address]*channelStore),
-		cfg:              cfg,
-		validator:        validator,
-		BaseEventStream:  types.NewBaseEventStream(ctx, cfg),
+		cfg:       cfg,
+		validator: minerValidator,
 	}
+	marketEventStream.EventService = types.NewEventService(ctx, types.EventServiceConfig[address.Address]{
+		Kind:          "market",
+		KeyName:       "miner",
+		LegacyMethods: []string{"SectorsUnsealPiece"},
+		// Chain services serve those miners should be controlled by themselves,so the user and miner cannot be forcibly bound here.
+		Validate: func(ctx context.Context, mAddr address.Address) error {
+			if err := minerValidator.Validate(ctx, mAddr); err != nil {
+				return fmt.Errorf("verify miner:%s failed:%w", mAddr.String(), err)
+			}
+			return nil
+		},
+		OnAdded: func(mAddr address.Address, channel *types.ChannelInfo) {
+			ctx, _ := tag.New(channel.Ctx, tag.Upsert(metrics.IPKey, channel.Ip), tag.Upsert(metrics.MinerAddressKey, mAddr.String()),
+				tag.Upsert(metrics.MinerTypeKey, "market"))
+			metrics.MinerRegister.Tick(ctx)
+			metrics.MinerSource.Tick(ctx)
+		},
+	}, cfg)
 	return marketEventStream
 }
 
 func (m *MarketEventStream) ListenMarketEvent(ctx context.Context, policy *gtypes.MarketRegisterPolicy) (<-chan *gtypes.RequestEvent, error) {
+	return m.ListenMarketEventWithCapacity(ctx, policy, nil)
+}
+
+// ListenMarketEventWithCapacity registers a channel which accepts at most capacity.MaxConcurrent requests at the same time,
+// capacity is nil for the clients not aware of it
+func (m *MarketEventStream) ListenMarketEventWithCapacity(ctx context.Context, policy *gtypes.MarketRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *gtypes.RequestEvent, error) {
 	ip, exist := core.CtxGetTokenLocation(ctx)
 	if !exist {
 		return nil, fmt.Errorf("ip not exist")
 	}
+	return m.Register(ctx, policy.Miner, ip, types.RegisterOptions{Capacity: capacity})
+}
 
-	// Chain services serve those miners should be controlled by themselves,so the user and miner cannot be forcibly bound here.
-	err := m.validator.Validate(ctx, policy.Miner)
-	if err != nil {
-		return nil, fmt.Errorf("verify miner:%s failed:%w", policy.Miner.String(), err)
-	}
-
-	out := make(chan *gtypes.RequestEvent, m.cfg.RequestQueueSize)
-	channel := types.NewChannelInfo(ctx, ip, out)
-	mAddr := policy.Miner
-	m.connLk.Lock()
-	var channelStore *channelStore
-	var ok bool
-	if channelStore, ok = m.minerConnections[mAddr]; !ok {
-		channelStore = newChannelStore()
-		m.minerConnections[policy.Miner] = channelStore
-	}
-
-	m.connLk.Unlock()
-	_ = channelStore.addChanel(channel)
-	log.Infof("add new connections %s for miner(market) %s", channel.ChannelId, mAddr)
-	go func() {
-		connectBytes, err := json.Marshal(gtypes.ConnectedCompleted{
-			ChannelId: channel.ChannelId,
-		})
-		defer close(out)
-		if err != nil {
-			log.Errorf("marshal failed %v", err)
-			return
-		}
-
-		ctx, _ = tag.New(ctx, tag.Upsert(metrics.IPKey, ip), tag.Upsert(metrics.MinerAddressKey, mAddr.String()),
-			tag.Upsert(metrics.MinerTypeKey, "market"))
-		metrics.MinerRegister.Tick(ctx)
-		metrics.MinerSource.Tick(ctx)
-
-		out <- &gtypes.RequestEvent{
-			ID:         sharedTypes.NewUUID(),
-			Method:     "InitConnect",
-			Payload:    connectBytes,
-			CreateTime: time.Now(),
-			Result:     nil,
-		} // no response
-		<-ctx.Done()
-		m.connLk.Lock()
-		defer m.connLk.Unlock() // connection read and remove should in one lock
-		channelStore := m.minerConnections[mAddr]
-		_ = channelStore.removeChanel(channel)
-		if channelStore.empty() {
-			delete(m.minerConnections, mAddr)
-		}
-		log.Infof("remove connections %s of miner(market) %s", channel.ChannelId, mAddr)
-	}()
-	return out, nil
+// DisconnectMiner closes all the connections of miner
+func (m *MarketEventStream) DisconnectMiner(ctx context.Context, mAddr address.Address) error {
+	return m.DisconnectKey(mAddr)
 }
 
 func (m *MarketEventStream) ResponseMarketEvent(ctx context.Context, resp *gtypes.ResponseEvent) error {
 	return m.ResponseEvent(ctx, resp)
 }
 
+// HasMiner reports whether the miner is connected to this gateway
+func (m *MarketEventStream) HasMiner(mAddr address.Address) bool {
+	return m.Has(mAddr)
+}
+
+// ListConnectedMiners returns the miners connected to this gateway
+func (m *MarketEventStream) ListConnectedMiners(ctx context.Context) ([]address.Address, error) {
+	return m.Keys(), nil
+}
+
 func (m *MarketEventStream) ListMarketConnectionsState(ctx context.Context) ([]gtypes.MarketConnectionState, error) {
 	var result []gtypes.MarketConnectionState
-	for addr, conn := range m.minerConnections {
+	for addr, states := range m.AllConnectStates() {
 		result = append(result, gtypes.MarketConnectionState{
 			Addr: addr,
-			Conn: *conn.getChannelState(),
+			Conn: *states,
 		})
 	}
 	return result, nil
 }
 
 func (m *MarketEventStream) SectorsUnsealPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) (gtypes.UnsealState, error) {
-	reqBody := gtypes.UnsealRequest{
-		PieceCid: pieceCid,
-		Miner:    miner,
-		Sid:      sid,
-		Offset:   offset,
-		Size:     size,
-		Dest:     dest,
-	}
-
-	payload, err := json.Marshal(reqBody)
-	if err != nil {
-		return gtypes.UnsealStateFailed, err
+	if m.cfg.ValidateCaller {
+		if err := m.validator.Validate(ctx, miner); err != nil {
+			return gtypes.UnsealStateFailed, fmt.Errorf("verify caller of miner:%s failed:%w", miner.String(), err)
+		}
 	}
 
-	channels, err := m.getChannels(miner)
+	channels, err := m.Channels(miner)
 	if err != nil {
 		return gtypes.UnsealStateFailed, err
 	}
 
 	start := time.Now()
-	var state gtypes.UnsealState
-	err = m.SendRequest(ctx, channels, "SectorsUnsealPiece", payload, &state)
+	state, err := types.Call[gtypes.UnsealState](ctx, m.BaseEventStream, channels, "SectorsUnsealPiece", types.PriorityUnseal,
+		gtypes.UnsealRequest{
+			PieceCid: pieceCid,
+			Miner:    miner,
+			Sid:      sid,
+			Offset:   offset,
+			Size:     size,
+			Dest:     dest,
+		})
 	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.MinerAddressKey, miner.String())},
 		metrics.SectorsUnsealPiece.M(metrics.SinceInMilliseconds(start)))
 
 	return state, err
 }
 
-func (m *MarketEventStream) getChannels(mAddr address.Address) ([]*types.ChannelInfo, error) {
-	m.connLk.Lock()
-	var channelStore *channelStore
-	var ok bool
-	if channelStore, ok = m.minerConnections[mAddr]; !ok {
-		m.connLk.Unlock()
-		return nil, fmt.Errorf("no connections for this miner %s", mAddr)
+func (m *MarketEventStream) marketChannels(ctx context.Context, miner address.Address) ([]*types.ChannelInfo, error) {
+	if m.cfg.ValidateCaller {
+		if err := m.validator.Validate(ctx, miner); err != nil {
+			return nil, fmt.Errorf("verify caller of miner:%s failed:%w", miner.String(), err)
+		}
 	}
-	m.connLk.Unlock()
+	return m.Channels(miner)
+}
 
-	channels, err := channelStore.getChannelListByMiners()
+// IsUnsealed asks the market client of miner whether the range of the piece in sector sid is unsealed
+func (m *MarketEventStream) IsUnsealed(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error) {
+	channels, err := m.marketChannels(ctx, miner)
 	if err != nil {
-		return nil, fmt.Errorf("cannot find any connection for miner %s", mAddr)
+		return false, err
+	}
+	return types.Call[bool](ctx, m.BaseEventStream, channels, "IsUnsealed", types.PriorityDefault, types.PieceRangeRequest{
+		Miner:    miner,
+		PieceCid: pieceCid,
+		Sid:      sid,
+		Offset:   offset,
+		Size:     size,
+	})
+}
+
+// LocatePiece asks the market client of miner for the sectors storing the piece
+func (m *MarketEventStream) LocatePiece(ctx context.Context, miner address.Address, pieceCid cid.Cid) ([]types.PieceLocation, error) {
+	channels, err := m.marketChannels(ctx, miner)
+	if err != nil {
+		return nil, err
+	}
+	return types.Call[[]types.PieceLocation](ctx, m.BaseEventStream, channels, "LocatePiece", types.PriorityDefault, types.LocatePieceRequest{
+		Miner:    miner,
+		PieceCid: pieceCid,
+	})
+}
+
+// ReadPiece streams the unsealed range of the piece in sector sid, which is read from the market client of miner
+// in chunks of PieceChunkSize bytes, the stream ends after the last chunk or a chunk with error
+func (m *MarketEventStream) ReadPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (<-chan *types.PieceChunk, error) {
+	if size == 0 {
+		return nil, fmt.Errorf("read empty range of piece %s", pieceCid)
+	}
+	if m.cfg.MaxPieceReadSize > 0 && uint64(size) > m.cfg.MaxPieceReadSize {
+		return nil, fmt.Errorf("read size %d exceeds the limit %d", size, m.cfg.MaxPieceReadSize)
+	}
+	if _, err := m.marketChannels(ctx, miner); err != nil {
+		return nil, err
+	}
+
+	chunkSize := m.cfg.PieceChunkSize
+	if chunkSize == 0 || chunkSize > types.MaxPieceChunkSize {
+		chunkSize = types.MaxPieceChunkSize
+	}
+
+	out := make(chan *types.PieceChunk, 1)
+	send := func(chunk *types.PieceChunk) bool {
+		select {
+		case out <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(out)
+		start := time.Now()
+		defer func() {
+			_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.MinerAddressKey, miner.String())},
+				metrics.ReadPiece.M(metrics.SinceInMilliseconds(start)))
+		}()
+
+		for read := uint64(0); read < uint64(size); {
+			n := min(chunkSize, uint64(size)-read)
+			chunkOffset := offset + sharedTypes.UnpaddedByteIndex(read)
+			// fetch the channels for each chunk, so that the read continues after the client reconnects
+			data, err := m.readChunk(ctx, miner, pieceCid, sid, chunkOffset, abi.UnpaddedPieceSize(n))
+			if err != nil {
+				send(&types.PieceChunk{Offset: chunkOffset, Err: err.Error()})
+				return
+			}
+			if !send(&types.PieceChunk{Offset: chunkOffset, Data: data}) {
+				return
+			}
+			read += n
+		}
+	}()
+	return out, nil
+}
+
+func (m *MarketEventStream) readChunk(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) ([]byte, error) {
+	channels, err := m.Channels(miner)
+	if err != nil {
+		return nil, err
+	}
+	data, err := types.Call[[]byte](ctx, m.BaseEventStream, channels, "ReadPiece", types.PriorityDefault, types.PieceRangeRequest{
+		Miner:    miner,
+		PieceCid: pieceCid,
+		Sid:      sid,
+		Offset:   offset,
+		Size:     size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != uint64(size) {
+		return nil, fmt.Errorf("expect %d bytes at offset %d but got %d", size, offset, len(data))
 	}
-	return channels, nil
+	return data, nil
 }
@@ -2,7 +2,11 @@
 package marketevent
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"testing"
 	"time"
 
@@ -15,6 +19,7 @@ import (
 	"github.com/ipfs-force-community/sophon-auth/core"
 
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
 
 	"github.com/ipfs-force-community/sophon-gateway/testhelper"
 	"github.com/ipfs-force-community/sophon-gateway/types"
@@ -22,6 +27,7 @@ import (
 	"github.com/ipfs-force-community/sophon-gateway/validator/mocks"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 )
 
 func TestListenMarketEvent(t *testing.T) {
@@ -132,6 +138,195 @@ func TestUnsealed(t *testing.T) {
 		_, err = marketEvent.SectorsUnsealPiece(ctx, minerAddr, pieceCid, sid, offset, size, dest)
 		require.EqualError(t, err, "mock error")
 	})
+
+	t.Run("validate caller", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		marketEvent := setupMarketEvent(t, walletAccount, minerAddr)
+		marketEvent.cfg.ValidateCaller = true
+		handler := testhelper.NewMarketHandler(t)
+		client := NewMarketEventClient(marketEvent, minerAddr, handler, log.With())
+		go client.ListenMarketRequest(core.CtxWithName(core.CtxWithTokenLocation(ctx, "127.1.1.1"), walletAccount))
+		client.WaitReady(ctx)
+
+		sid := abi.SectorNumber(10)
+		size := abi.UnpaddedPieceSize(100)
+		offset := sharedTypes.UnpaddedByteIndex(100)
+		dest := ""
+		pieceCid, err := cid.Decode("bafy2bzaced2kktxdkqw5pey5of3wtahz5imm7ta4ymegah466dsc5fonj73u2")
+		require.NoError(t, err)
+		handler.SetSectorsUnsealPieceExpect(pieceCid, minerAddr, sid, offset, size, dest, false)
+
+		_, err = marketEvent.SectorsUnsealPiece(core.CtxWithName(ctx, "other_account"), minerAddr, pieceCid, sid, offset, size, dest)
+		require.Contains(t, err.Error(), "verify caller of miner")
+
+		_, err = marketEvent.SectorsUnsealPiece(ctx, minerAddr, pieceCid, sid, offset, size, dest)
+		require.Contains(t, err.Error(), "user name not exist")
+
+		_, err = marketEvent.SectorsUnsealPiece(core.CtxWithName(ctx, walletAccount), minerAddr, pieceCid, sid, offset, size, dest)
+		require.NoError(t, err)
+	})
+}
+
+// retrievalHandler serves the piece data of sector 1 starting at offset 0
+type retrievalHandler struct {
+	*testhelper.MarketHandler
+	data  []byte
+	reads int
+}
+
+func (h *retrievalHandler) IsUnsealed(_ context.Context, _ address.Address, _ cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error) {
+	return sid == 1 && uint64(offset)+uint64(size) <= uint64(len(h.data)), nil
+}
+
+func (h *retrievalHandler) LocatePiece(_ context.Context, _ address.Address, _ cid.Cid) ([]types.PieceLocation, error) {
+	return []types.PieceLocation{{Sector: 1, Size: abi.UnpaddedPieceSize(len(h.data)), Unsealed: true}}, nil
+}
+
+func (h *retrievalHandler) ReadPiece(_ context.Context, _ address.Address, _ cid.Cid, sid abi.SectorNumber, offset sharedTypes.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	h.reads++
+	if sid != 1 {
+		return nil, fmt.Errorf("sector %d not found", sid)
+	}
+	end := min(uint64(offset)+uint64(size), uint64(len(h.data)))
+	return io.NopCloser(bytes.NewReader(h.data[offset:end])), nil
+}
+
+func TestMarketRetrieval(t *testing.T) {
+	walletAccount := "client_account"
+	addrGetter := address.NewForTestGetter()
+	minerAddr := addrGetter()
+	pieceCid, err := cid.Decode("bafy2bzaced2kktxdkqw5pey5of3wtahz5imm7ta4ymegah466dsc5fonj73u2")
+	require.NoError(t, err)
+
+	setup := func(ctx context.Context, handler types.MarketHandler) *MarketEventStream {
+		marketEvent := setupMarketEvent(t, walletAccount, minerAddr)
+		marketEvent.cfg.PieceChunkSize = 10
+		marketEvent.cfg.MaxPieceReadSize = 100
+		client := NewMarketEventClient(marketEvent, minerAddr, handler, log.With())
+		go client.ListenMarketRequest(core.CtxWithName(core.CtxWithTokenLocation(ctx, "127.1.1.1"), walletAccount))
+		client.WaitReady(ctx)
+		return marketEvent
+	}
+	readAll := func(ch <-chan *types.PieceChunk) ([]byte, error) {
+		var buf []byte
+		for chunk := range ch {
+			if len(chunk.Err) > 0 {
+				return buf, fmt.Errorf("%s", chunk.Err)
+			}
+			require.Equal(t, sharedTypes.UnpaddedByteIndex(len(buf)+5), chunk.Offset)
+			buf = append(buf, chunk.Data...)
+		}
+		return buf, nil
+	}
+
+	t.Run("locate and read piece", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handler := &retrievalHandler{MarketHandler: testhelper.NewMarketHandler(t), data: bytes.Repeat([]byte("0123456789abcdef"), 4)}
+		marketEvent := setup(ctx, handler)
+
+		locations, err := marketEvent.LocatePiece(ctx, minerAddr, pieceCid)
+		require.NoError(t, err)
+		require.Equal(t, []types.PieceLocation{{Sector: 1, Size: 64, Unsealed: true}}, locations)
+		unsealed, err := marketEvent.IsUnsealed(ctx, minerAddr, pieceCid, 1, 5, 25)
+		require.NoError(t, err)
+		require.True(t, unsealed)
+
+		ch, err := marketEvent.ReadPiece(ctx, minerAddr, pieceCid, 1, 5, 25)
+		require.NoError(t, err)
+		data, err := readAll(ch)
+		require.NoError(t, err)
+		require.Equal(t, handler.data[5:30], data)
+		require.Equal(t, 3, handler.reads)
+	})
+
+	t.Run("read failed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handler := &retrievalHandler{MarketHandler: testhelper.NewMarketHandler(t), data: make([]byte, 20)}
+		marketEvent := setup(ctx, handler)
+
+		// the range exceeds the piece data
+		ch, err := marketEvent.ReadPiece(ctx, minerAddr, pieceCid, 1, 5, 25)
+		require.NoError(t, err)
+		data, err := readAll(ch)
+		require.Contains(t, err.Error(), "unexpected EOF")
+		require.Len(t, data, 10)
+
+		ch, err = marketEvent.ReadPiece(ctx, minerAddr, pieceCid, 2, 5, 10)
+		require.NoError(t, err)
+		_, err = readAll(ch)
+		require.Contains(t, err.Error(), "sector 2 not found")
+
+		_, err = marketEvent.ReadPiece(ctx, minerAddr, pieceCid, 1, 0, 101)
+		require.Contains(t, err.Error(), "exceeds the limit")
+		_, err = marketEvent.ReadPiece(ctx, addrGetter(), pieceCid, 1, 0, 10)
+		require.Contains(t, err.Error(), "no connections for this miner")
+	})
+
+	t.Run("unsupported client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		marketEvent := setup(ctx, testhelper.NewMarketHandler(t))
+
+		_, err := marketEvent.IsUnsealed(ctx, minerAddr, pieceCid, 1, 0, 10)
+		require.Contains(t, err.Error(), "unsupported method IsUnsealed")
+	})
+}
+
+func TestSendRequestLeak(t *testing.T) {
+	walletAccount := "client_account"
+	minerAddr := address.NewForTestGetter()()
+	marketEvent := setupMarketEvent(t, walletAccount, minerAddr)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sid := abi.SectorNumber(10)
+	size := abi.UnpaddedPieceSize(100)
+	offset := sharedTypes.UnpaddedByteIndex(100)
+	pieceCid, err := cid.Decode("bafy2bzaced2kktxdkqw5pey5of3wtahz5imm7ta4ymegah466dsc5fonj73u2")
+	require.NoError(t, err)
+	handler := testhelper.NewMarketHandler(t)
+	handler.SetSectorsUnsealPieceExpect(pieceCid, minerAddr, sid, offset, size, "", false)
+
+	clientCtx, clientCancel := context.WithCancel(core.CtxWithName(core.CtxWithTokenLocation(ctx, "127.1.1.1"), walletAccount))
+	for i := 0; i < 2; i++ {
+		client := NewMarketEventClient(marketEvent, minerAddr, handler, log.With())
+		go client.ListenMarketRequest(clientCtx)
+		client.WaitReady(ctx)
+	}
+
+	channels, err := marketEvent.Channels(minerAddr)
+	require.NoError(t, err)
+	require.Len(t, channels, 2)
+	// the first channel fails, the request is sent to the others and the losing one is canceled
+	closedCtx, closedCancel := context.WithCancel(ctx)
+	closedCancel()
+	closed := types.NewChannelInfo(closedCtx, "127.1.1.2", make(chan *gtypes.RequestEvent), 1)
+	payload, err := json.Marshal(gtypes.UnsealRequest{
+		PieceCid: pieceCid,
+		Miner:    minerAddr,
+		Sid:      sid,
+		Offset:   offset,
+		Size:     size,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		var state gtypes.UnsealState
+		require.NoError(t, marketEvent.SendRequest(ctx, append([]*types.ChannelInfo{closed}, channels...), "SectorsUnsealPiece", payload, &state))
+	}
+
+	// the caller returns before response
+	callCtx, callCancel := context.WithCancel(ctx)
+	callCancel()
+	_, err = marketEvent.SectorsUnsealPiece(callCtx, minerAddr, pieceCid, sid, offset, size, "")
+	require.Error(t, err)
+
+	clientCancel()
+	require.Eventually(t, func() bool { return marketEvent.PendingRequests() == 0 }, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool { return !marketEvent.HasMiner(minerAddr) }, time.Second*5, time.Millisecond*10)
 }
 
 func TestListMarketConnectionsState(t *testing.T) {
@@ -0,0 +1,45 @@
+package marketevent
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+
+	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// NewMarketRegisterClients connect to all gateway urls, the returned closer closes all of them
+func NewMarketRegisterClients(ctx context.Context, urls []string, token string) ([]v2API.IMarketServiceProvider, jsonrpc.ClientCloser, error) {
+	return types.DialGateways(ctx, urls, token, NewMarketRegisterClient)
+}
+
+// MultiMarketEvent registers the miner to several gateways simultaneously, so that requests can still be
+// served through the others when one of the gateways is down
+type MultiMarketEvent struct {
+	*types.MultiClient[*MarketEvent]
+}
+
+func NewMultiMarketEvent(clients []v2API.IMarketServiceProvider, mAddr address.Address, marketHandler types.MarketHandler, log *zap.SugaredLogger) *MultiMarketEvent {
+	events := make([]*MarketEvent, 0, len(clients))
+	for i, client := range clients {
+		events = append(events, NewMarketEventClient(client, mAddr, marketHandler, log.With("gateway", i)))
+	}
+	return &MultiMarketEvent{MultiClient: types.NewMultiClient(events, (*MarketEvent).ListenMarketRequest)}
+}
+
+// SetCapacity advertises the capacity to all gateways, must be called before listening
+func (e *MultiMarketEvent) SetCapacity(capacity types.ChannelCapacity) {
+	for _, event := range e.Clients() {
+		event.SetCapacity(capacity)
+	}
+}
+
+// ListenMarketRequest returns once gave up all the gateways
+func (e *MultiMarketEvent) ListenMarketRequest(ctx context.Context) error {
+	return e.Listen(ctx)
+}
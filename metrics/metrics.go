@@ -20,6 +20,15 @@ var (
 	MinerTypeKey, _ = tag.NewKey("miner_type")
 
 	IPKey, _ = tag.NewKey("ip")
+
+	AuthMethodKey, _  = tag.NewKey("auth_method")
+	CacheResultKey, _ = tag.NewKey("cache_result")
+
+	ChannelTypeKey, _  = tag.NewKey("channel_type")
+	EvictReasonKey, _  = tag.NewKey("evict_reason")
+	BreakerStateKey, _ = tag.NewKey("breaker_state")
+
+	TaskNameKey, _ = tag.NewKey("task")
 )
 
 // Distribution
@@ -42,11 +51,24 @@ var (
 	MinerNum        = metrics.NewInt64("miner/num", "Wallet count", "", MinerTypeKey)
 	MinerConnNum    = metrics.NewInt64("miner/conn_num", "Miner connection count", "", MinerTypeKey)
 
+	// auth
+	AuthCache = metrics.NewCounter("auth/cache", "Result of looking up cached sophon-auth call", AuthMethodKey, CacheResultKey)
+
+	// channel
+	ChannelEvict   = metrics.NewCounter("channel/evict", "Connection closed as it is no longer authorized", ChannelTypeKey, EvictReasonKey)
+	ChannelBreaker = metrics.NewCounter("channel/breaker", "Circuit breaker state changes of connections", IPKey, BreakerStateKey)
+
+	// cluster
+	ClusterForward = metrics.NewCounter("cluster/forward", "Requests forwarded to other gateway instances", ChannelTypeKey)
+
 	// method call
 	WalletSign         = stats.Float64("wallet_sign", "Call WalletSign spent time", stats.UnitMilliseconds)
+	WalletSignBatch    = stats.Float64("wallet_sign_batch", "Call WalletSignBatch spent time", stats.UnitMilliseconds)
 	WalletList         = stats.Float64("wallet_list", "Call WalletList spent time", stats.UnitMilliseconds)
 	ComputeProof       = stats.Float64("compute_proof", "Call ComputeProof spent time", stats.UnitMilliseconds)
 	SectorsUnsealPiece = stats.Float64("sectors_unseal_piece", "Call SectorsUnsealPiece spent time", stats.UnitMilliseconds)
+	PushTask           = stats.Float64("push_task", "Call PushTask spent time", stats.UnitMilliseconds)
+	ReadPiece          = stats.Float64("read_piece", "Call ReadPiece spent time", stats.UnitMilliseconds)
 )
 
 var (
@@ -79,6 +101,11 @@ var (
 		Aggregation: defaultMillisecondsDistribution,
 		TagKeys:     []tag.Key{WalletAccountKey},
 	}
+	walletSignBatchView = &view.View{
+		Measure:     WalletSignBatch,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{WalletAccountKey},
+	}
 	walletListView = &view.View{
 		Measure:     WalletList,
 		Aggregation: defaultMillisecondsDistribution,
@@ -94,6 +121,16 @@ var (
 		Aggregation: defaultMillisecondsDistribution,
 		TagKeys:     []tag.Key{MinerAddressKey},
 	}
+	pushTaskView = &view.View{
+		Measure:     PushTask,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{MinerAddressKey, TaskNameKey},
+	}
+	readPieceView = &view.View{
+		Measure:     ReadPiece,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{MinerAddressKey},
+	}
 )
 
 var views = append([]*view.View{
@@ -102,9 +139,12 @@ var views = append([]*view.View{
 	walletAddAddrView,
 	walletRemoveAddrView,
 	walletSignView,
+	walletSignBatchView,
 	walletListView,
 	computeProofView,
 	sectorsUnsealPieceView,
+	pushTaskView,
+	readPieceView,
 }, rpcMetrics.DefaultViews...)
 
 // SinceInMilliseconds returns the duration of time since the provide time as a float64.
@@ -0,0 +1,45 @@
+package proofevent
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+
+	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// NewProofRegisterClients connect to all gateway urls, the returned closer closes all of them
+func NewProofRegisterClients(ctx context.Context, urls []string, token string) ([]v2API.IProofServiceProvider, jsonrpc.ClientCloser, error) {
+	return types.DialGateways(ctx, urls, token, NewProofRegisterClient)
+}
+
+// MultiProofEvent registers the miner to several gateways simultaneously, so that requests can still be
+// served through the others when one of the gateways is down
+type MultiProofEvent struct {
+	*types.MultiClient[*ProofEvent]
+}
+
+func NewMultiProofEvent(clients []v2API.IProofServiceProvider, mAddr address.Address, proofHandler types.ProofHandler, log *zap.SugaredLogger) *MultiProofEvent {
+	events := make([]*ProofEvent, 0, len(clients))
+	for i, client := range clients {
+		events = append(events, NewProofEvent(client, mAddr, proofHandler, log.With("gateway", i)))
+	}
+	return &MultiProofEvent{MultiClient: types.NewMultiClient(events, (*ProofEvent).ListenProofRequest)}
+}
+
+// SetCapacity advertises the capacity to all gateways, must be called before listening
+func (e *MultiProofEvent) SetCapacity(capacity types.ChannelCapacity) {
+	for _, event := range e.Clients() {
+		event.SetCapacity(capacity)
+	}
+}
+
+// ListenProofRequest returns once gave up all the gateways
+func (e *MultiProofEvent) ListenProofRequest(ctx context.Context) error {
+	return e.Listen(ctx)
+}
@@ -2,9 +2,8 @@ package proofevent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"sync"
+	"slices"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -22,112 +21,72 @@ import (
 
 	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
 	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
-	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	sharedGatewayTypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
 )
 
 var log = logging.Logger("proof_stream")
 
+var ErrNoCapableWorker = fmt.Errorf("no capable worker")
+
 var _ v2API.IProofClient = (*ProofEventStream)(nil)
 
 type ProofEventStream struct {
-	connLk           sync.RWMutex
-	minerConnections map[address.Address]*channelStore
-	cfg              *types.RequestConfig
-	validator        validator.IAuthMinerValidator
-	*types.BaseEventStream
+	cfg       *types.RequestConfig
+	validator validator.IAuthMinerValidator
+	*types.EventService[address.Address]
 }
 
-func NewProofEventStream(ctx context.Context, validator validator.IAuthMinerValidator, cfg *types.RequestConfig) *ProofEventStream {
+func NewProofEventStream(ctx context.Context, minerValidator validator.IAuthMinerValidator, cfg *types.RequestConfig) *ProofEventStream {
 	proofEventStream := &ProofEventStream{
-		connLk:           sync.RWMutex{},
-		minerConnections: make(map[address.Address]*channelStore),
-		cfg:              cfg,
-		validator:        validator,
-		BaseEventStream:  types.NewBaseEventStream(ctx, cfg),
+		cfg:       cfg,
+		validator: minerValidator,
 	}
+	proofEventStream.EventService = types.NewEventService(ctx, types.EventServiceConfig[address.Address]{
+		Kind:          "proof",
+		KeyName:       "miner",
+		LegacyMethods: []string{"ComputeProof"},
+		// Chain services serve those miners should be controlled by themselves,so the user and miner cannot be forcibly bound here.
+		Validate: func(ctx context.Context, mAddr address.Address) error {
+			if err := minerValidator.Validate(ctx, mAddr); err != nil {
+				return fmt.Errorf("verify miner:%s failed:%w", mAddr.String(), err)
+			}
+			return nil
+		},
+		OnAdded: func(mAddr address.Address, channel *types.ChannelInfo) {
+			ctx, _ := minerTags(channel, mAddr)
+			metrics.MinerRegister.Tick(ctx)
+			metrics.MinerSource.Tick(ctx)
+		},
+		OnRemoved: func(mAddr address.Address, channel *types.ChannelInfo) {
+			ctx, _ := minerTags(channel, mAddr)
+			metrics.MinerUnregister.Tick(ctx)
+		},
+	}, cfg)
 	return proofEventStream
 }
 
+func minerTags(channel *types.ChannelInfo, mAddr address.Address) (context.Context, error) {
+	return tag.New(channel.Ctx, tag.Upsert(metrics.IPKey, channel.Ip), tag.Upsert(metrics.MinerAddressKey, mAddr.String()),
+		tag.Upsert(metrics.MinerTypeKey, "pprof"))
+}
+
 func (e *ProofEventStream) ListenProofEvent(ctx context.Context, policy *sharedGatewayTypes.ProofRegisterPolicy) (<-chan *sharedGatewayTypes.RequestEvent, error) {
+	return e.ListenProofEventWithCapacity(ctx, policy, nil)
+}
+
+// ListenProofEventWithCapacity registers a channel which accepts at most capacity.MaxConcurrent requests at the same time,
+// capacity is nil for the clients not aware of it
+func (e *ProofEventStream) ListenProofEventWithCapacity(ctx context.Context, policy *sharedGatewayTypes.ProofRegisterPolicy, capacity *types.ChannelCapacity) (<-chan *sharedGatewayTypes.RequestEvent, error) {
 	ip, exist := core.CtxGetTokenLocation(ctx)
 	if !exist {
 		return nil, fmt.Errorf("ip not exist")
 	}
+	return e.Register(ctx, policy.MinerAddress, ip, types.RegisterOptions{Capacity: capacity})
+}
 
-	// Chain services serve those miners should be controlled by themselves,so the user and miner cannot be forcibly bound here.
-	err := e.validator.Validate(ctx, policy.MinerAddress)
-	if err != nil {
-		return nil, fmt.Errorf("verify miner:%s failed:%w", policy.MinerAddress.String(), err)
-	}
-
-	out := make(chan *sharedGatewayTypes.RequestEvent, e.cfg.RequestQueueSize)
-	reqEventChan := make(chan *sharedGatewayTypes.RequestEvent, e.cfg.RequestQueueSize)
-	channel := types.NewChannelInfo(ctx, ip, reqEventChan)
-	mAddr := policy.MinerAddress
-	e.connLk.Lock()
-	var channelStore *channelStore
-	var ok bool
-	if channelStore, ok = e.minerConnections[mAddr]; !ok {
-		channelStore = newChannelStore()
-		e.minerConnections[policy.MinerAddress] = channelStore
-	}
-
-	removeChannel := func() {
-		e.connLk.Lock()
-		channelStore := e.minerConnections[mAddr]
-		e.connLk.Unlock()
-		_ = channelStore.removeChanel(channel)
-		if channelStore.empty() {
-			e.connLk.Lock()
-			delete(e.minerConnections, mAddr)
-			e.connLk.Unlock()
-		}
-
-		log.Infof("remove connections %s of miner(proof) %s", channel.ChannelId, mAddr)
-	}
-
-	e.connLk.Unlock()
-	_ = channelStore.addChanel(channel)
-	log.Infof("add new connections %s for miner(proof) %s", channel.ChannelId, mAddr)
-	go func() {
-		connectBytes, err := json.Marshal(sharedGatewayTypes.ConnectedCompleted{
-			ChannelId: channel.ChannelId,
-		})
-		if err != nil {
-			removeChannel()
-			close(out)
-			log.Errorf("marshal failed %s %v", channel.ChannelId, err)
-			return
-		}
-
-		ctx, _ = tag.New(ctx, tag.Upsert(metrics.IPKey, ip), tag.Upsert(metrics.MinerAddressKey, mAddr.String()),
-			tag.Upsert(metrics.MinerTypeKey, "pprof"))
-		metrics.MinerRegister.Tick(ctx)
-		metrics.MinerSource.Tick(ctx)
-
-		reqEventChan <- &sharedGatewayTypes.RequestEvent{
-			ID:         sharedTypes.NewUUID(),
-			Method:     "InitConnect",
-			Payload:    connectBytes,
-			CreateTime: time.Now(),
-			Result:     nil,
-		} // no response
-
-		// avoid data race
-		for {
-			select {
-			case <-ctx.Done():
-				removeChannel()
-				close(out)
-				metrics.MinerUnregister.Tick(ctx)
-				return
-			case c := <-reqEventChan:
-				out <- c
-			}
-		}
-	}()
-	return out, nil
+// DisconnectMiner closes all the connections of miner
+func (e *ProofEventStream) DisconnectMiner(ctx context.Context, mAddr address.Address) error {
+	return e.DisconnectKey(mAddr)
 }
 
 func (e *ProofEventStream) ResponseProofEvent(ctx context.Context, resp *sharedGatewayTypes.ResponseEvent) error {
@@ -135,26 +94,29 @@ func (e *ProofEventStream) ResponseProofEvent(ctx context.Context, resp *sharedG
 }
 
 func (e *ProofEventStream) ComputeProof(ctx context.Context, miner address.Address, sectorInfos []builtin.ExtendedSectorInfo, rand abi.PoStRandomness, height abi.ChainEpoch, nwVersion network.Version) ([]builtin.PoStProof, error) {
-	reqBody := sharedGatewayTypes.ComputeProofRequest{
-		SectorInfos: sectorInfos,
-		Rand:        rand,
-		Height:      height,
-		NWVersion:   nwVersion,
+	if e.cfg.ValidateCaller {
+		if err := e.validator.Validate(ctx, miner); err != nil {
+			return nil, fmt.Errorf("verify caller of miner:%s failed:%w", miner.String(), err)
+		}
 	}
 
-	payload, err := json.Marshal(reqBody)
+	channels, err := e.Channels(miner)
 	if err != nil {
 		return nil, err
 	}
-
-	channels, err := e.getChannels(miner)
+	channels, err = capableChannels(channels, sectorInfos, nwVersion)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("miner %s: %w", miner, err)
 	}
 
 	start := time.Now()
-	var result []builtin.PoStProof
-	err = e.SendRequest(ctx, channels, "ComputeProof", payload, &result)
+	result, err := types.Call[[]builtin.PoStProof](ctx, e.BaseEventStream, channels, "ComputeProof", types.PriorityWindowPoSt,
+		sharedGatewayTypes.ComputeProofRequest{
+			SectorInfos: sectorInfos,
+			Rand:        rand,
+			Height:      height,
+			NWVersion:   nwVersion,
+		})
 	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.MinerAddressKey, miner.String())},
 		metrics.ComputeProof.M(metrics.SinceInMilliseconds(start)))
 	if err == nil {
@@ -164,39 +126,70 @@ func (e *ProofEventStream) ComputeProof(ctx context.Context, miner address.Addre
 	return nil, err
 }
 
-func (e *ProofEventStream) getChannels(mAddr address.Address) ([]*types.ChannelInfo, error) {
-	e.connLk.Lock()
-	var channelStore *channelStore
-	var ok bool
-	if channelStore, ok = e.minerConnections[mAddr]; !ok {
-		e.connLk.Unlock()
-		return nil, fmt.Errorf("no connections for this miner %s", mAddr)
+// capableChannels filters the channels able to prove all the sectors, a channel is capable if it supports
+// either the winning or the window PoSt proof type of the seal proof of each sector
+func capableChannels(channels []*types.ChannelInfo, sectorInfos []builtin.ExtendedSectorInfo, nwVersion network.Version) ([]*types.ChannelInfo, error) {
+	var required [][]abi.RegisteredPoStProof
+	seen := make(map[abi.RegisteredSealProof]struct{})
+	for _, sector := range sectorInfos {
+		if _, ok := seen[sector.SealProof]; ok {
+			continue
+		}
+		seen[sector.SealProof] = struct{}{}
+		winning, err := sector.SealProof.RegisteredWinningPoStProof()
+		if err != nil {
+			return nil, fmt.Errorf("sector %d: %w", sector.SectorNumber, err)
+		}
+		window, err := sector.SealProof.RegisteredWindowPoStProofByNetworkVersion(nwVersion)
+		if err != nil {
+			return nil, fmt.Errorf("sector %d: %w", sector.SectorNumber, err)
+		}
+		required = append(required, []abi.RegisteredPoStProof{winning, window})
 	}
-	e.connLk.Unlock()
 
-	channels, err := channelStore.getChannelListByMiners()
-	if err != nil {
-		return nil, fmt.Errorf("cannot find any connection for miner %s", mAddr)
+	capable := make([]*types.ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		if supportsAll(channel.Capacity.ProofTypes, required) {
+			capable = append(capable, channel)
+		}
+	}
+	if len(capable) == 0 {
+		return nil, fmt.Errorf("%w for proof types %v", ErrNoCapableWorker, required)
 	}
-	return channels, nil
+	return capable, nil
 }
 
-func (e *ProofEventStream) ListConnectedMiners(ctx context.Context) ([]address.Address, error) {
-	e.connLk.Lock()
-	defer e.connLk.Unlock()
-	var miners []address.Address
-	for miner := range e.minerConnections {
-		miners = append(miners, miner)
+func supportsAll(supported []abi.RegisteredPoStProof, required [][]abi.RegisteredPoStProof) bool {
+	if len(supported) == 0 {
+		return true
+	}
+	for _, anyOf := range required {
+		ok := false
+		for _, proofType := range anyOf {
+			if slices.Contains(supported, proofType) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
 	}
-	return miners, nil
+	return true
 }
 
-func (e *ProofEventStream) ListMinerConnection(ctx context.Context, addr address.Address) (*sharedGatewayTypes.MinerState, error) {
-	e.connLk.Lock()
-	defer e.connLk.Unlock()
+// HasMiner reports whether the miner is connected to this gateway
+func (e *ProofEventStream) HasMiner(mAddr address.Address) bool {
+	return e.Has(mAddr)
+}
 
-	if store, ok := e.minerConnections[addr]; ok {
-		return store.getChannelState(), nil
+func (e *ProofEventStream) ListConnectedMiners(ctx context.Context) ([]address.Address, error) {
+	return e.Keys(), nil
+}
+
+func (e *ProofEventStream) ListMinerConnection(ctx context.Context, addr address.Address) (*sharedGatewayTypes.MinerState, error) {
+	if states, ok := e.ConnectStates(addr); ok {
+		return (*sharedGatewayTypes.MinerState)(states), nil
 	}
 	return nil, fmt.Errorf("miner %s not exit", addr)
 }
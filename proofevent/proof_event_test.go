@@ -18,10 +18,13 @@ import (
 	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
 	types2 "github.com/filecoin-project/venus/venus-shared/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+	"github.com/ipfs-force-community/sophon-auth/auth"
 	"github.com/ipfs-force-community/sophon-auth/core"
 	gtypes "github.com/ipfs-force-community/sophon-gateway/types"
 	"github.com/ipfs-force-community/sophon-gateway/validator"
+	"github.com/ipfs-force-community/sophon-gateway/validator/mocks"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 	"golang.org/x/net/context"
 )
 
@@ -45,7 +48,7 @@ func TestListenProofEvent(t *testing.T) {
 		initBody := &types.ConnectedCompleted{}
 		err = json.Unmarshal(initReq.Payload, initBody)
 		require.NoError(t, err)
-		channel, err := proof.getChannels(addr1)
+		channel, err := proof.Channels(addr1)
 		require.NoError(t, err)
 		require.Equal(t, len(channel), 1)
 		require.Equal(t, channel[0].ChannelId, initBody.ChannelId)
@@ -109,6 +112,68 @@ func TestListenProofEvent(t *testing.T) {
 		require.Contains(t, err.Error(), "verify miner:")
 	})
 
+	t.Run("evict revoked connection", func(t *testing.T) {
+		minerValidator := &validator.MockAuthMinerValidator{ValidatedAddr: []address.Address{addr1}}
+		proof := NewProofEventStream(context.Background(), minerValidator, gtypes.DefaultConfig())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx = core.CtxWithTokenLocation(ctx, "127.1.1.1")
+		requestCh, err := proof.ListenProofEvent(ctx, &types.ProofRegisterPolicy{
+			MinerAddress: addr1,
+		})
+		require.NoError(t, err)
+		initReq := <-requestCh
+		require.Equal(t, "InitConnect", initReq.Method)
+
+		// still valid
+		proof.Revalidate(nil)
+		channels, err := proof.Channels(addr1)
+		require.NoError(t, err)
+		require.Len(t, channels, 1)
+
+		// miner unbound from the user
+		minerValidator.ValidatedAddr = nil
+		proof.Revalidate(nil)
+		select {
+		case <-time.After(time.Second * 30):
+			t.Errorf("unable to wait for closed channel within 30s")
+		case _, ok := <-requestCh:
+			require.False(t, ok)
+		}
+		require.Eventually(t, func() bool {
+			_, err := proof.Channels(addr1)
+			return err != nil
+		}, time.Second*5, time.Millisecond*50)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		proof := setupProofEvent(t, []address.Address{addr1})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx = core.CtxWithTokenLocation(ctx, "127.1.1.1")
+		requestCh, err := proof.ListenProofEvent(ctx, &types.ProofRegisterPolicy{
+			MinerAddress: addr1,
+		})
+		require.NoError(t, err)
+		initReq := <-requestCh
+		require.Equal(t, "InitConnect", initReq.Method)
+
+		require.NoError(t, proof.Drain(ctx))
+		req := <-requestCh
+		// skip the protocol negotiation
+		if req.Method == gtypes.MethodProtocol {
+			req = <-requestCh
+		}
+		require.Equal(t, gtypes.MethodReconnect, req.Method)
+
+		_, err = proof.ListenProofEvent(ctx, &types.ProofRegisterPolicy{
+			MinerAddress: addr1,
+		})
+		require.ErrorIs(t, err, gtypes.ErrDraining)
+		_, err = proof.ComputeProof(ctx, addr1, []builtin.ExtendedSectorInfo{}, []byte{}, 100, 16)
+		require.ErrorIs(t, err, gtypes.ErrDraining)
+	})
+
 	t.Run("no ip exit", func(t *testing.T) {
 		proof := setupProofEvent(t, []address.Address{addr1})
 		ctx, cancel := context.WithCancel(context.Background())
@@ -187,7 +252,7 @@ func TestComputeProofEvent(t *testing.T) {
 
 		{
 			ctx := context.Background()
-			channels, err := proof.getChannels(addr)
+			channels, err := proof.Channels(addr)
 			require.NoError(t, err)
 			var result []builtin.PoStProof
 			// stm: @VENUSGATEWAY_PROOF_EVENT_COMPUTE_PROOF_002
@@ -229,6 +294,47 @@ func TestComputeProofEvent(t *testing.T) {
 		require.Contains(t, err.Error(), "no connections for this miner")
 	})
 
+	t.Run("validate caller", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		account := "proof_account"
+		proof := setupProofEventWithAuth(t, account, addr)
+		proof.cfg.ValidateCaller = true
+		expectInfo := []builtin.ExtendedSectorInfo{
+			{
+				SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+				SectorNumber: 100,
+				SectorKey:    nil,
+				SealedCID:    cid.Undef,
+			},
+		}
+		expectRand := []byte{1, 23}
+		expectEpoch := abi.ChainEpoch(100)
+		expectVersion := network.Version(10)
+		expectProof := []builtin.PoStProof{
+			{
+				PoStProof:  abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+				ProofBytes: []byte{3, 4},
+			},
+		}
+		handler := testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, expectProof, false)
+		proofClient := NewProofEvent(proof, addr, handler, log.With())
+
+		go proofClient.ListenProofRequest(core.CtxWithName(core.CtxWithTokenLocation(ctx, "127.1.1.1"), account))
+		proofClient.WaitReady(ctx)
+
+		// the miner does not belong to the caller
+		_, err := proof.ComputeProof(core.CtxWithName(ctx, "other_account"), addr, expectInfo, expectRand, expectEpoch, expectVersion)
+		require.Contains(t, err.Error(), "verify caller of miner")
+		// no caller
+		_, err = proof.ComputeProof(ctx, addr, expectInfo, expectRand, expectEpoch, expectVersion)
+		require.Contains(t, err.Error(), "verify caller of miner")
+
+		result, err := proof.ComputeProof(core.CtxWithName(ctx, account), addr, expectInfo, expectRand, expectEpoch, expectVersion)
+		require.NoError(t, err)
+		require.Equal(t, expectProof, result)
+	})
+
 	t.Run("incorrect result  error", func(t *testing.T) {
 		proof := setupProofEvent(t, []address.Address{addr})
 		{
@@ -280,6 +386,142 @@ func TestComputeProofEvent(t *testing.T) {
 	})
 }
 
+func TestComputeProofCapability(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = core.CtxWithTokenLocation(ctx, "127.1.1.1")
+	addr := address.NewForTestGetter()()
+	proof := setupProofEvent(t, []address.Address{addr})
+
+	expectInfo := []builtin.ExtendedSectorInfo{
+		{
+			SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+			SectorNumber: 100,
+			SealedCID:    cid.Undef,
+		},
+	}
+	expectRand := []byte{1, 23}
+	expectEpoch := abi.ChainEpoch(100)
+	expectVersion := network.Version(10)
+	expectProof := []builtin.PoStProof{
+		{
+			PoStProof:  abi.RegisteredPoStProof_StackedDrgWinning2KiBV1,
+			ProofBytes: []byte{3, 4},
+		},
+	}
+
+	// the worker for 32GiB sectors fails if the request is dispatched to it
+	worker32G := NewProofEvent(proof, addr, testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, nil, true), log.With())
+	worker32G.SetCapacity(gtypes.ChannelCapacity{ProofTypes: []abi.RegisteredPoStProof{
+		abi.RegisteredPoStProof_StackedDrgWinning32GiBV1,
+		abi.RegisteredPoStProof_StackedDrgWindow32GiBV1_1,
+	}})
+	go worker32G.ListenProofRequest(ctx)
+	worker32G.WaitReady(ctx)
+
+	_, err := proof.ComputeProof(ctx, addr, expectInfo, expectRand, expectEpoch, expectVersion)
+	require.ErrorIs(t, err, ErrNoCapableWorker)
+
+	worker2K := NewProofEvent(proof, addr, testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, expectProof, false), log.With())
+	worker2K.SetCapacity(gtypes.ChannelCapacity{ProofTypes: []abi.RegisteredPoStProof{
+		abi.RegisteredPoStProof_StackedDrgWinning2KiBV1,
+	}})
+	go worker2K.ListenProofRequest(ctx)
+	worker2K.WaitReady(ctx)
+
+	for i := 0; i < 5; i++ {
+		result, err := proof.ComputeProof(ctx, addr, expectInfo, expectRand, expectEpoch, expectVersion)
+		require.NoError(t, err)
+		require.Equal(t, expectProof, result)
+	}
+
+	_, err = proof.ComputeProof(ctx, addr, []builtin.ExtendedSectorInfo{{SealProof: abi.RegisteredSealProof(-1)}}, expectRand, expectEpoch, expectVersion)
+	require.Error(t, err)
+}
+
+func TestSendRequestLeak(t *testing.T) {
+	addr := address.NewForTestGetter()()
+	proof := setupProofEvent(t, []address.Address{addr})
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expectInfo := []builtin.ExtendedSectorInfo{
+		{
+			SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+			SectorNumber: 100,
+			SealedCID:    cid.Undef,
+		},
+	}
+	expectRand := []byte{1, 23}
+	expectEpoch := abi.ChainEpoch(100)
+	expectVersion := network.Version(10)
+	expectProof := []builtin.PoStProof{
+		{
+			PoStProof:  abi.RegisteredPoStProof_StackedDrgWindow2KiBV1,
+			ProofBytes: []byte{3, 4},
+		},
+	}
+	clientCtx, clientCancel := context.WithCancel(core.CtxWithTokenLocation(ctx, "127.1.1.1"))
+	for i := 0; i < 2; i++ {
+		client := NewProofEvent(proof, addr, testhelper.NewProofHander(t, expectInfo, expectRand, expectEpoch, expectVersion, expectProof, false), log.With())
+		go client.ListenProofRequest(clientCtx)
+		client.WaitReady(ctx)
+	}
+
+	channels, err := proof.Channels(addr)
+	require.NoError(t, err)
+	require.Len(t, channels, 2)
+	// the first channel fails, the request is sent to the others and the losing one is canceled
+	closedCtx, closedCancel := context.WithCancel(ctx)
+	closedCancel()
+	closed := gtypes.NewChannelInfo(closedCtx, "127.1.1.2", make(chan *types.RequestEvent), 1)
+	payload, err := json.Marshal(types.ComputeProofRequest{
+		SectorInfos: expectInfo,
+		Rand:        expectRand,
+		Height:      expectEpoch,
+		NWVersion:   expectVersion,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		var result []builtin.PoStProof
+		require.NoError(t, proof.SendRequest(ctx, append([]*gtypes.ChannelInfo{closed}, channels...), "ComputeProof", payload, &result))
+		require.Equal(t, expectProof, result)
+	}
+
+	// the caller returns before response
+	callCtx, callCancel := context.WithCancel(ctx)
+	callCancel()
+	_, err = proof.ComputeProof(callCtx, addr, expectInfo, expectRand, expectEpoch, expectVersion)
+	require.Error(t, err)
+
+	clientCancel()
+	require.Eventually(t, func() bool { return proof.PendingRequests() == 0 }, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool {
+		_, err := proof.Channels(addr)
+		return err != nil
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestProofHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := address.NewForTestGetter()()
+	cfg := gtypes.DefaultConfig()
+	cfg.Heartbeat = gtypes.HeartbeatConfig{Interval: time.Millisecond * 50, MaxMisses: 2}
+	proof := NewProofEventStream(ctx, &validator.MockAuthMinerValidator{ValidatedAddr: []address.Address{addr}}, cfg)
+
+	client := NewProofEvent(proof, addr, nil, log.With())
+	go client.ListenProofRequest(core.CtxWithTokenLocation(ctx, "127.1.1.1"))
+	client.WaitReady(ctx)
+
+	require.Eventually(t, func() bool {
+		states, err := proof.ListChannelStates(ctx)
+		require.NoError(t, err)
+		return len(states) == 1 && !states[0].LastSeen.IsZero() && states[0].RTT > 0
+	}, time.Second*2, time.Millisecond*10)
+}
+
 func TestListConnectedMiners(t *testing.T) {
 	addrGetter := address.NewForTestGetter()
 	addr1 := addrGetter()
@@ -331,8 +573,33 @@ func TestListMinerConnection(t *testing.T) {
 	connetions, err := proof.ListMinerConnection(ctx, addr1)
 	require.NoError(t, err)
 	require.Equal(t, connetions.ConnectionCount, 2)
+
+	states, err := proof.ListChannelStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	for _, state := range states {
+		require.Equal(t, "proof", state.Type)
+		require.Equal(t, addr1.String(), state.Owner)
+		require.Equal(t, gtypes.BreakerClosed.String(), state.Breaker)
+	}
 }
 
 func setupProofEvent(t *testing.T, validateAddr []address.Address) *ProofEventStream {
 	return NewProofEventStream(context.Background(), &validator.MockAuthMinerValidator{ValidatedAddr: validateAddr}, gtypes.DefaultConfig())
 }
+
+// setupProofEventWithAuth validates the miners by the callers in context, miners belong to userName
+func setupProofEventWithAuth(t *testing.T, userName string, miners ...address.Address) *ProofEventStream {
+	authClient := mocks.NewMockAuthClient()
+	user := &auth.OutputUser{
+		Id:     "id",
+		Name:   userName,
+		State:  1,
+		Miners: []*auth.OutputMiner{},
+	}
+	for _, m := range miners {
+		user.Miners = append(user.Miners, &auth.OutputMiner{Miner: m, User: userName})
+	}
+	authClient.AddMockUser(context.Background(), user)
+	return NewProofEventStream(context.Background(), validator.NewMinerValidator(authClient), gtypes.DefaultConfig())
+}
@@ -0,0 +1,58 @@
+package taskevent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+
+	"github.com/filecoin-project/venus/venus-shared/api"
+	"github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/api/extapi"
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// TaskEvent receives the tasks pushed to a worker, the handler of each task name is registered by Handle
+type TaskEvent struct {
+	client extapi.ITaskServiceProvider
+	policy types.TaskRegisterPolicy
+	log    *zap.SugaredLogger
+	*types.EventClient
+}
+
+func NewTaskRegisterClient(ctx context.Context, url, token string) (extapi.ITaskServiceProvider, jsonrpc.ClientCloser, error) {
+	headers := http.Header{}
+	headers.Add(api.AuthorizationHeader, "Bearer "+token)
+	client, closer, err := extapi.NewIGatewayRPC(ctx, url, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, closer, nil
+}
+
+func NewTaskEventClient(client extapi.ITaskServiceProvider, mAddr address.Address, tag string, log *zap.SugaredLogger) *TaskEvent {
+	return &TaskEvent{
+		client:      client,
+		policy:      types.TaskRegisterPolicy{Miner: mAddr, Tag: tag},
+		log:         log,
+		EventClient: types.NewEventClient(log, client.ResponseTaskEvent),
+	}
+}
+
+func (e *TaskEvent) ListenTaskRequest(ctx context.Context) error {
+	e.log.Infof("start task event listening")
+	return e.Run(ctx, e.listen)
+}
+
+func (e *TaskEvent) listen(ctx context.Context) (<-chan *gateway.RequestEvent, error) {
+	ch, err := e.client.ListenTaskEvent(ctx, &e.policy)
+	if err != nil {
+		return nil, fmt.Errorf("listen task event call failed: %w", err)
+	}
+	return ch, nil
+}
@@ -0,0 +1,133 @@
+package taskevent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs-force-community/sophon-auth/core"
+
+	gtypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+	"github.com/ipfs-force-community/sophon-gateway/types"
+	"github.com/ipfs-force-community/sophon-gateway/validator"
+)
+
+var log = logging.Logger("task_stream")
+
+// reservedMethods are handled by the event clients themselves, tasks can't be named after them
+var reservedMethods = []string{"InitConnect", types.MethodPing, types.MethodReconnect, types.MethodProtocol}
+
+// workerKey routes the tasks to the workers registered with the same miner and tag
+type workerKey struct {
+	Miner address.Address
+	Tag   string
+}
+
+func (k workerKey) String() string {
+	return k.Miner.String() + "/" + k.Tag
+}
+
+// TaskEventStream pushes the named tasks of services to the workers, eg. the sealing workers behind NAT
+type TaskEventStream struct {
+	cfg       *types.RequestConfig
+	validator validator.IAuthMinerValidator
+	*types.EventService[workerKey]
+}
+
+func NewTaskEventStream(ctx context.Context, minerValidator validator.IAuthMinerValidator, cfg *types.RequestConfig) *TaskEventStream {
+	taskEventStream := &TaskEventStream{
+		cfg:       cfg,
+		validator: minerValidator,
+	}
+	taskEventStream.EventService = types.NewEventService(ctx, types.EventServiceConfig[workerKey]{
+		Kind:    "task",
+		KeyName: "worker",
+		Validate: func(ctx context.Context, key workerKey) error {
+			if err := minerValidator.Validate(ctx, key.Miner); err != nil {
+				return fmt.Errorf("verify miner:%s failed:%w", key.Miner.String(), err)
+			}
+			return nil
+		},
+		OnAdded: func(key workerKey, channel *types.ChannelInfo) {
+			ctx, _ := minerTags(channel, key.Miner)
+			metrics.MinerRegister.Tick(ctx)
+			metrics.MinerSource.Tick(ctx)
+		},
+		OnRemoved: func(key workerKey, channel *types.ChannelInfo) {
+			ctx, _ := minerTags(channel, key.Miner)
+			metrics.MinerUnregister.Tick(ctx)
+		},
+	}, cfg)
+	return taskEventStream
+}
+
+func minerTags(channel *types.ChannelInfo, mAddr address.Address) (context.Context, error) {
+	return tag.New(channel.Ctx, tag.Upsert(metrics.IPKey, channel.Ip), tag.Upsert(metrics.MinerAddressKey, mAddr.String()),
+		tag.Upsert(metrics.MinerTypeKey, "task"))
+}
+
+// ListenTaskEvent registers a worker of the miner and tag in policy
+func (e *TaskEventStream) ListenTaskEvent(ctx context.Context, policy *types.TaskRegisterPolicy) (<-chan *gtypes.RequestEvent, error) {
+	ip, exist := core.CtxGetTokenLocation(ctx)
+	if !exist {
+		return nil, fmt.Errorf("ip not exist")
+	}
+	return e.Register(ctx, workerKey{Miner: policy.Miner, Tag: policy.Tag}, ip, types.RegisterOptions{})
+}
+
+func (e *TaskEventStream) ResponseTaskEvent(ctx context.Context, resp *gtypes.ResponseEvent) error {
+	return e.ResponseEvent(ctx, resp)
+}
+
+// PushTask sends the task name with payload to one of the workers of miner and workerTag, and returns the result of the worker,
+// the task is not retried on the other workers once failed, as it may be not idempotent
+func (e *TaskEventStream) PushTask(ctx context.Context, miner address.Address, workerTag string, name string, payload json.RawMessage) (json.RawMessage, error) {
+	if len(name) == 0 || slices.Contains(reservedMethods, name) {
+		return nil, fmt.Errorf("invalid task name %q", name)
+	}
+	if e.cfg.ValidateCaller {
+		if err := e.validator.Validate(ctx, miner); err != nil {
+			return nil, fmt.Errorf("verify caller of miner:%s failed:%w", miner.String(), err)
+		}
+	}
+
+	channels, err := e.Channels(workerKey{Miner: miner, Tag: workerTag})
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var result json.RawMessage
+	err = e.SendRequestOnce(ctx, channels, name, payload, types.PriorityDefault, &result)
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.MinerAddressKey, miner.String()), tag.Upsert(metrics.TaskNameKey, name)},
+		metrics.PushTask.M(metrics.SinceInMilliseconds(start)))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DisconnectMiner closes all the worker connections of miner
+func (e *TaskEventStream) DisconnectMiner(ctx context.Context, mAddr address.Address) error {
+	found := false
+	for _, key := range e.Keys() {
+		if key.Miner != mAddr {
+			continue
+		}
+		found = true
+		_ = e.DisconnectKey(key)
+	}
+	if !found {
+		return fmt.Errorf("no connections for this miner %s", mAddr)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// stm: #unit
+package taskevent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	"github.com/ipfs-force-community/sophon-auth/core"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+	"github.com/ipfs-force-community/sophon-gateway/validator"
+)
+
+type addPieceRequest struct {
+	SectorNumber uint64
+	Size         uint64
+}
+
+func TestPushTask(t *testing.T) {
+	addrGetter := address.NewForTestGetter()
+	addr1 := addrGetter()
+	addr2 := addrGetter()
+
+	setup := func(ctx context.Context, tag string) (*TaskEventStream, *TaskEvent) {
+		task := NewTaskEventStream(ctx, &validator.MockAuthMinerValidator{ValidatedAddr: []address.Address{addr1}}, types.DefaultConfig())
+		client := NewTaskEventClient(task, addr1, tag, log.With())
+		client.Handle("AddPiece", types.TypedHandler(func(ctx context.Context, req addPieceRequest) (uint64, error) {
+			if req.Size == 0 {
+				return 0, fmt.Errorf("empty piece of sector %d", req.SectorNumber)
+			}
+			return req.Size, nil
+		}))
+		go func() {
+			_ = client.ListenTaskRequest(core.CtxWithTokenLocation(ctx, "127.1.1.1"))
+		}()
+		client.WaitReady(ctx)
+		// wait for the methods of worker to be advertised
+		require.Eventually(t, func() bool {
+			channels, err := task.Channels(workerKey{Miner: addr1, Tag: tag})
+			return err == nil && channels[0].Protocol() != nil
+		}, time.Second*5, time.Millisecond*10)
+		return task, client
+	}
+
+	t.Run("push to worker of tag", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		task, _ := setup(ctx, "pc1")
+
+		payload, err := json.Marshal(addPieceRequest{SectorNumber: 1, Size: 2048})
+		require.NoError(t, err)
+		result, err := task.PushTask(ctx, addr1, "pc1", "AddPiece", payload)
+		require.NoError(t, err)
+		var size uint64
+		require.NoError(t, json.Unmarshal(result, &size))
+		require.Equal(t, uint64(2048), size)
+
+		// the error of worker
+		payload, err = json.Marshal(addPieceRequest{SectorNumber: 1})
+		require.NoError(t, err)
+		_, err = task.PushTask(ctx, addr1, "pc1", "AddPiece", payload)
+		require.Contains(t, err.Error(), "empty piece of sector 1")
+
+		// no worker of tag
+		_, err = task.PushTask(ctx, addr1, "pc2", "AddPiece", payload)
+		require.Contains(t, err.Error(), "no connections for this worker")
+		// reserved method
+		_, err = task.PushTask(ctx, addr1, "pc1", types.MethodPing, nil)
+		require.Contains(t, err.Error(), "invalid task name")
+
+		states, err := task.ListChannelStates(ctx)
+		require.NoError(t, err)
+		require.Len(t, states, 1)
+		require.Equal(t, "task", states[0].Type)
+		require.Equal(t, addr1.String()+"/pc1", states[0].Owner)
+	})
+
+	t.Run("not retry task on other workers", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		task, _ := setup(ctx, "pc1")
+		var calls atomic.Int32
+		started := make(chan sharedTypes.UUID, 2)
+		release := make(chan struct{})
+		defer close(release)
+		for i := 0; i < 2; i++ {
+			client := NewTaskEventClient(task, addr1, "pc1", log.With())
+			client.Handle("SealPreCommit1", func(ctx context.Context, _ []byte) (interface{}, error) {
+				if calls.Add(1) > 1 {
+					return nil, nil
+				}
+				started <- client.ChannelID()
+				<-release
+				return nil, nil
+			})
+			go func() {
+				_ = client.ListenTaskRequest(core.CtxWithTokenLocation(ctx, "127.1.1.1"))
+			}()
+			client.WaitReady(ctx)
+		}
+		require.Eventually(t, func() bool {
+			channels, err := task.Channels(workerKey{Miner: addr1, Tag: "pc1"})
+			if err != nil || len(channels) != 3 {
+				return false
+			}
+			for _, channel := range channels {
+				if channel.Protocol() == nil {
+					return false
+				}
+			}
+			return true
+		}, time.Second*5, time.Millisecond*10)
+
+		// the connection of the worker running the task is lost
+		go func() {
+			channelID := <-started
+			if !task.DisconnectChannel(ctx, channelID) {
+				t.Errorf("channel %s not found", channelID)
+			}
+		}()
+		_, err := task.PushTask(ctx, addr1, "pc1", "SealPreCommit1", nil)
+		require.ErrorIs(t, err, types.ErrCloseChannel)
+		time.Sleep(time.Millisecond * 100)
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("unknown task of worker", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		task, _ := setup(ctx, "")
+
+		_, err := task.PushTask(ctx, addr1, "", "SealPreCommit1", nil)
+		require.Contains(t, err.Error(), "unsupported method SealPreCommit1")
+		require.Equal(t, 0, task.PendingRequests())
+	})
+
+	t.Run("unauthorized miner", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		task := NewTaskEventStream(ctx, &validator.MockAuthMinerValidator{ValidatedAddr: []address.Address{addr1}}, types.DefaultConfig())
+		_, err := task.ListenTaskEvent(core.CtxWithTokenLocation(ctx, "127.1.1.1"), &types.TaskRegisterPolicy{Miner: addr2, Tag: "pc1"})
+		require.Contains(t, err.Error(), "verify miner:")
+	})
+
+	t.Run("disconnect miner", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		task, _ := setup(ctx, "pc1")
+
+		require.NoError(t, task.DisconnectMiner(ctx, addr1))
+		require.False(t, task.Has(workerKey{Miner: addr1, Tag: "pc1"}))
+		require.Error(t, task.DisconnectMiner(ctx, addr1))
+	})
+}
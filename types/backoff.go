@@ -0,0 +1,56 @@
+package types
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delay before the next retry, which grows exponentially with the failed attempts
+type Backoff struct {
+	Min    time.Duration
+	Max    time.Duration
+	Factor float64
+	// Jitter randomizes the delay by the fraction, eg. 0.2 means ±20%, to avoid clients retrying at the same time
+	Jitter float64
+}
+
+var DefaultBackoff = Backoff{
+	Min:    time.Second,
+	Max:    time.Minute,
+	Factor: 2,
+	Jitter: 0.2,
+}
+
+// Duration returns the delay after attempt consecutive failures, attempt starts from 0
+func (b Backoff) Duration(attempt int) time.Duration {
+	delay := float64(b.Min) * math.Pow(b.Factor, float64(attempt))
+	if delay > float64(b.Max) || math.IsInf(delay, 0) || math.IsNaN(delay) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (rand.Float64()*2 - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// WaitAnyReady returns once any of waits returns, the others will be canceled
+func WaitAnyReady(ctx context.Context, waits ...func(context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{}, len(waits))
+	for _, wait := range waits {
+		go func(wait func(context.Context)) {
+			wait(ctx)
+			done <- struct{}{}
+		}(wait)
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,25 @@
+// stm: #unit
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: time.Second * 10, Factor: 2}
+	require.Equal(t, time.Second, b.Duration(0))
+	require.Equal(t, time.Second*2, b.Duration(1))
+	require.Equal(t, time.Second*8, b.Duration(3))
+	require.Equal(t, time.Second*10, b.Duration(4))
+	require.Equal(t, time.Second*10, b.Duration(10000))
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := b.Duration(1)
+		require.GreaterOrEqual(t, d, time.Second)
+		require.LessOrEqual(t, d, time.Second*3)
+	}
+}
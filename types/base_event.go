@@ -19,11 +19,23 @@ var log = logging.Logger("gateway_stream")
 
 var ErrCloseChannel = fmt.Errorf("channel closed")
 var ErrRequestTimeout = fmt.Errorf("timer clean this request due to exceed wait time")
+var ErrDraining = fmt.Errorf("gateway is draining, try other gateways")
+var ErrQueueFull = fmt.Errorf("request queue of channel is full")
+var ErrUnsupportedMethod = fmt.Errorf("unsupported method")
 
 type BaseEventStream struct {
 	reqLk     sync.RWMutex
 	idRequest map[sharedTypes.UUID]*types.RequestEvent
 	cfg       *RequestConfig
+
+	drainLk  sync.Mutex
+	draining bool
+	inflight int
+	drained  chan struct{}
+
+	// slotFreed is closed and replaced once a channel frees capacity
+	slotLk    sync.Mutex
+	slotFreed chan struct{}
 }
 
 func NewBaseEventStream(ctx context.Context, cfg *RequestConfig) *BaseEventStream {
@@ -31,15 +43,38 @@ func NewBaseEventStream(ctx context.Context, cfg *RequestConfig) *BaseEventStrea
 		reqLk:     sync.RWMutex{},
 		idRequest: make(map[sharedTypes.UUID]*types.RequestEvent),
 		cfg:       cfg,
+		slotFreed: make(chan struct{}),
 	}
 	go baseEventStream.cleanRequests(ctx)
 	return baseEventStream
 }
 
 func (e *BaseEventStream) SendRequest(ctx context.Context, channels []*ChannelInfo, method string, payload []byte, result interface{}) error {
+	return e.SendRequestWithPriority(ctx, channels, method, payload, PriorityDefault, result)
+}
+
+// SendRequestWithPriority sends the request before the requests of lower priority waiting on the same channel
+func (e *BaseEventStream) SendRequestWithPriority(ctx context.Context, channels []*ChannelInfo, method string, payload []byte, priority Priority, result interface{}) error {
+	return e.sendRequest(ctx, channels, method, payload, priority, result, true)
+}
+
+// SendRequestOnce sends the request to one of channels, it is not sent to the others if failed,
+// for the requests not idempotent which must not be handled by several clients
+func (e *BaseEventStream) SendRequestOnce(ctx context.Context, channels []*ChannelInfo, method string, payload []byte, priority Priority, result interface{}) error {
+	return e.sendRequest(ctx, channels, method, payload, priority, result, false)
+}
+
+func (e *BaseEventStream) sendRequest(ctx context.Context, channels []*ChannelInfo, method string, payload []byte, priority Priority, result interface{}, fanOut bool) error {
 	if len(channels) == 0 {
 		return fmt.Errorf("send request must have channel")
 	}
+	if channels = supportedChannels(ctx, channels, method); len(channels) == 0 {
+		return fmt.Errorf("%w %s by the clients", ErrUnsupportedMethod, method)
+	}
+	if err := e.beginRequest(); err != nil {
+		return err
+	}
+	defer e.endRequest()
 
 	processResp := func(resp *types.ResponseEvent) error {
 		if len(resp.Error) > 0 {
@@ -51,57 +86,155 @@ func (e *BaseEventStream) SendRequest(ctx context.Context, channels []*ChannelIn
 		}
 		return nil
 	}
-	firstChanel := channels[0]
-	resp, err := e.sendOnce(ctx, firstChanel, method, payload)
+	firstChanel, err := e.acquireChannel(ctx, channels)
+	if err != nil {
+		return err
+	}
+	resp, err := e.sendOnce(ctx, firstChanel, method, payload, priority)
+	e.releaseChannel(firstChanel)
 	if err == nil {
 		return processResp(resp)
 	}
 
-	if ctx.Err() != nil || len(channels) == 1 || isTimeoutError(err) { // if ctx have done before, not to try others
+	if !fanOut || ctx.Err() != nil || len(channels) == 1 || isTimeoutError(err) { // if ctx have done before, not to try others
 		return err
 	}
 
 	// code below unable to work as expect , because there no way to detect network issue in gateway,
 	log.Warnf("the first channel is fail, try to other channel")
 
-	var lk sync.Mutex
-	var respOnce sync.Once
-	otherChannels := channels[1:]
-	respCh := make(chan *types.ResponseEvent)
-	errRespCount := 0
+	otherChannels := make([]*ChannelInfo, 0, len(channels)-1)
+	for _, channel := range e.usableChannels(channels) {
+		if channel != firstChanel {
+			otherChannels = append(otherChannels, channel)
+		}
+	}
+
+	// the losing attempts are canceled once a response is received or the caller returns,
+	// respCh is buffered so that no attempt blocks after the caller returns
+	fanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type attempt struct {
+		resp *types.ResponseEvent
+		err  error
+	}
+	respCh := make(chan attempt, len(otherChannels))
 	for _, channel := range otherChannels {
-		go func(channel *ChannelInfo) {
-			respEvent, err := e.sendOnce(ctx, channel, method, payload)
-			if err != nil {
-				lk.Lock()
-				errRespCount++
-				log.Errorf("send request %s failed %v", method, err)
-				// all requests failed
-				if errRespCount == len(otherChannels) {
-					respCh <- &types.ResponseEvent{
-						Error: fmt.Sprintf("all request failed: %s %v", method, err),
-					}
-				}
-				lk.Unlock()
-				return
+		go func() {
+			resp, err := e.sendAcquired(fanCtx, channel, method, payload, priority)
+			respCh <- attempt{resp: resp, err: err}
+		}()
+	}
+
+	for range otherChannels {
+		select {
+		case r := <-respCh:
+			if r.err == nil {
+				return processResp(r.resp)
 			}
-			// only send once, avoid goroutine leak
-			respOnce.Do(func() {
-				respCh <- respEvent
-			})
-		}(channel)
+			log.Errorf("send request %s failed %v", method, r.err)
+			err = r.err
+		case <-ctx.Done():
+			return fmt.Errorf("request cancel by context")
+		}
+	}
+	return fmt.Errorf("all request failed: %s %v", method, err)
+}
+
+func (e *BaseEventStream) beginRequest() error {
+	e.drainLk.Lock()
+	defer e.drainLk.Unlock()
+	if e.draining {
+		return ErrDraining
+	}
+	e.inflight++
+	return nil
+}
+
+func (e *BaseEventStream) endRequest() {
+	e.drainLk.Lock()
+	defer e.drainLk.Unlock()
+	e.inflight--
+	if e.draining && e.inflight == 0 {
+		close(e.drained)
+	}
+}
+
+// IsDraining reports whether the stream stops accepting new connections and requests
+func (e *BaseEventStream) IsDraining() bool {
+	e.drainLk.Lock()
+	defer e.drainLk.Unlock()
+	return e.draining
+}
+
+// Drain stops accepting new requests and waits for the outstanding requests to complete until ctx done
+func (e *BaseEventStream) Drain(ctx context.Context) error {
+	e.drainLk.Lock()
+	if !e.draining {
+		e.draining = true
+		e.drained = make(chan struct{})
+		if e.inflight == 0 {
+			close(e.drained)
+		}
 	}
+	drained := e.drained
+	e.drainLk.Unlock()
 
 	select {
-	case resp := <-respCh:
-		return processResp(resp)
+	case <-drained:
+		return nil
 	case <-ctx.Done():
-		return fmt.Errorf("request cancel by context")
+		e.drainLk.Lock()
+		defer e.drainLk.Unlock()
+		return fmt.Errorf("%d requests not completed: %w", e.inflight, ctx.Err())
 	}
 }
 
-func (e *BaseEventStream) sendOnce(ctx context.Context, channel *ChannelInfo, method string, payload []byte) (response *types.ResponseEvent, err error) {
+// NotifyReconnect asks the client of channel to connect to other gateways, the notification will be dropped
+// if the request queue of channel is full
+func (e *BaseEventStream) NotifyReconnect(channel *ChannelInfo, reason string) {
+	payload, err := json.Marshal(ReconnectRequest{Reason: reason})
+	if err != nil {
+		log.Errorf("marshal reconnect request failed: %v", err)
+		return
+	}
+	if channel.Ctx.Err() != nil {
+		return
+	}
+	if !channel.Enqueue(&types.RequestEvent{
+		ID:         sharedTypes.NewUUID(),
+		Method:     MethodReconnect,
+		Payload:    payload,
+		CreateTime: time.Now(),
+		Result:     nil,
+	}, PriorityControl) { // no response
+		log.Warnf("request queue of channel %s is full, unable to notify reconnect", channel.ChannelId)
+	}
+}
+
+// sendAcquired waits for free capacity of channel before sending the request
+func (e *BaseEventStream) sendAcquired(ctx context.Context, channel *ChannelInfo, method string, payload []byte, priority Priority) (*types.ResponseEvent, error) {
+	if _, err := e.acquireChannel(ctx, []*ChannelInfo{channel}); err != nil {
+		return nil, err
+	}
+	defer e.releaseChannel(channel)
+	return e.sendOnce(ctx, channel, method, payload, priority)
+}
+
+func (e *BaseEventStream) sendOnce(ctx context.Context, channel *ChannelInfo, method string, payload []byte, priority Priority) (response *types.ResponseEvent, err error) {
 	id := sharedTypes.NewUUID()
+	sent := false
+	// the request will never be responded to the caller, remove it
+	defer func() {
+		// the missed heartbeats are handled by StartHeartbeat, and the legacy clients don't respond the protocol
+		if sent && method != MethodPing && method != MethodProtocol {
+			e.recordResult(channel, response, err)
+		}
+		if err != nil {
+			channel.queue.remove(id)
+			e.removeRequest(id)
+		}
+	}()
 	resultCh := make(chan *types.ResponseEvent, 1)
 	request := &types.RequestEvent{
 		ID:         id,
@@ -114,15 +247,18 @@ func (e *BaseEventStream) sendOnce(ctx context.Context, channel *ChannelInfo, me
 	e.idRequest[id] = request
 	e.reqLk.Unlock()
 
-	select {
-	case <-channel.Ctx.Done():
-		return nil, ErrCloseChannel
-	case <-ctx.Done():
-		return nil, fmt.Errorf("send request cancel by context %w", ctx.Err())
-	default:
-		channel.OutBound <- request //NOTICE this may be panic, but will catch by recover and try other, should never have  other panic
-		log.Debug("send request %s to %s", method, channel.Ip)
+	if err := channel.queue.push(ctx, channel.Ctx, request, priority, e.cfg.EnqueueTimeout); err != nil {
+		if errors.Is(err, ErrCloseChannel) {
+			return nil, err
+		}
+		if errors.Is(err, ErrQueueFull) {
+			log.Warnf("request queue of channel %s is full, %d requests waiting", channel.ChannelId, channel.queue.len())
+			return nil, fmt.Errorf("%w: %s", err, channel.ChannelId)
+		}
+		return nil, fmt.Errorf("send request cancel by context %w", err)
 	}
+	sent = true
+	log.Debugf("send request %s to %s", method, channel.Ip)
 
 	// wait for result
 	// timeout here
@@ -132,12 +268,27 @@ func (e *BaseEventStream) sendOnce(ctx context.Context, channel *ChannelInfo, me
 	case <-ctx.Done():
 		return nil, fmt.Errorf("cancel by context %w", ctx.Err())
 	case respEvent := <-resultCh:
+		channel.touch()
 		return respEvent, nil
 	}
 }
 
+// PendingRequests returns the count of the requests waiting for response
+func (e *BaseEventStream) PendingRequests() int {
+	e.reqLk.RLock()
+	defer e.reqLk.RUnlock()
+	return len(e.idRequest)
+}
+
+func (e *BaseEventStream) removeRequest(id sharedTypes.UUID) {
+	e.reqLk.Lock()
+	delete(e.idRequest, id)
+	e.reqLk.Unlock()
+}
+
 func (e *BaseEventStream) cleanRequests(ctx context.Context) {
 	tm := time.NewTicker(e.cfg.ClearInterval)
+	defer tm.Stop()
 	for {
 		select {
 		case <-tm.C:
@@ -183,6 +334,14 @@ func (e *BaseEventStream) ResponseEvent(ctx context.Context, resp *types.Respons
 	return nil
 }
 
+// IsUnsupportedMethod reports whether the client responded that it doesn't support the method of request
+func IsUnsupportedMethod(err error) bool {
+	if !reflect2.IsNil(err) {
+		return strings.Contains(err.Error(), ErrUnsupportedMethod.Error())
+	}
+	return false
+}
+
 func isTimeoutError(err error) bool {
 	if !reflect2.IsNil(err) {
 		return strings.Contains(err.Error(), ErrRequestTimeout.Error())
@@ -5,12 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 )
 
 type mockParams struct {
@@ -286,6 +288,214 @@ func TestSendRequest(t *testing.T) {
 	})
 }
 
+func TestDrain(t *testing.T) {
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+
+	setup := func(t *testing.T, ctx context.Context, delay time.Duration) (*BaseEventStream, *mockClient, chan error) {
+		eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+		client := setupClient(t, eventSteam, "127.1.1.1")
+		client.delayToReponse = delay
+		go client.start(ctx)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- eventSteam.SendRequest(ctx, []*ChannelInfo{client.channel}, "mock_method", parms, &mockResult{})
+		}()
+		require.Eventually(t, func() bool {
+			eventSteam.drainLk.Lock()
+			defer eventSteam.drainLk.Unlock()
+			return eventSteam.inflight == 1
+		}, time.Second*5, time.Millisecond*10)
+		return eventSteam, client, errCh
+	}
+
+	t.Run("wait outstanding requests", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam, client, errCh := setup(t, ctx, time.Millisecond*500)
+
+		drainCtx, drainCancel := context.WithTimeout(ctx, time.Second*10)
+		defer drainCancel()
+		require.NoError(t, eventSteam.Drain(drainCtx))
+		require.NoError(t, <-errCh)
+		require.True(t, eventSteam.IsDraining())
+
+		err := eventSteam.SendRequest(ctx, []*ChannelInfo{client.channel}, "mock_method", parms, &mockResult{})
+		require.ErrorIs(t, err, ErrDraining)
+		// drain again
+		require.NoError(t, eventSteam.Drain(drainCtx))
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam, _, errCh := setup(t, ctx, time.Second*2)
+
+		drainCtx, drainCancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer drainCancel()
+		err := eventSteam.Drain(drainCtx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Contains(t, err.Error(), "1 requests not completed")
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("notify reconnect", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+		requestCh := make(chan *types.RequestEvent, 1)
+		channel := NewChannelInfo(ctx, "127.1.1.1", requestCh, 1)
+
+		eventSteam.NotifyReconnect(channel, "drain")
+		req := <-requestCh
+		require.Equal(t, MethodReconnect, req.Method)
+		var reconnectReq ReconnectRequest
+		require.NoError(t, json.Unmarshal(req.Payload, &reconnectReq))
+		require.Equal(t, "drain", reconnectReq.Reason)
+
+		// do not block when the queue is full
+		requestCh <- &types.RequestEvent{}
+		eventSteam.NotifyReconnect(channel, "drain")
+	})
+}
+
+func TestCapacity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+
+	eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+	busy := setupClient(t, eventSteam, "127.1.1.1")
+	busy.channel.Capacity.MaxConcurrent = 1
+	busy.delayToReponse = time.Millisecond * 500
+	go busy.start(ctx)
+	idle := setupClient(t, eventSteam, "127.1.1.2")
+	idle.channel.Capacity.MaxConcurrent = 1
+	go idle.start(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- eventSteam.SendRequest(ctx, []*ChannelInfo{busy.channel}, "mock_method", parms, &mockResult{})
+	}()
+	require.Eventually(t, func() bool { return busy.channel.Inflight() == 1 }, time.Second*5, time.Millisecond*10)
+
+	// skip the channel without free capacity
+	start := time.Now()
+	require.NoError(t, eventSteam.SendRequest(ctx, []*ChannelInfo{busy.channel, idle.channel}, "mock_method", parms, &mockResult{}))
+	require.Less(t, time.Since(start), time.Millisecond*500)
+	require.Equal(t, 0, idle.channel.Inflight())
+
+	// cancel while waiting for free capacity
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer waitCancel()
+	err = eventSteam.SendRequest(waitCtx, []*ChannelInfo{busy.channel}, "mock_method", parms, &mockResult{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// queue until the channel is free
+	require.NoError(t, eventSteam.SendRequest(ctx, []*ChannelInfo{busy.channel}, "mock_method", parms, &mockResult{}))
+	require.NoError(t, <-errCh)
+	require.Equal(t, 0, busy.channel.Inflight())
+}
+
+func TestBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.EnqueueTimeout = time.Millisecond * 500
+	eventSteam := NewBaseEventStream(ctx, cfg)
+
+	// the client never reads requests, so the queue keeps full
+	stuckCtx, stuckCancel := context.WithCancel(ctx)
+	stuck := NewChannelInfo(stuckCtx, "127.1.1.1", make(chan *types.RequestEvent), 1)
+	require.True(t, stuck.Enqueue(&types.RequestEvent{}, PriorityDefault))
+	require.Eventually(t, func() bool { return stuck.queue.len() == 0 }, time.Second, time.Millisecond*10)
+	require.True(t, stuck.Enqueue(&types.RequestEvent{}, PriorityDefault))
+
+	healthy := setupClient(t, eventSteam, "127.1.1.2")
+	go healthy.start(ctx)
+
+	// fail after waiting EnqueueTimeout
+	start := time.Now()
+	err = eventSteam.SendRequest(ctx, []*ChannelInfo{stuck}, "mock_method", parms, &mockResult{})
+	require.ErrorIs(t, err, ErrQueueFull)
+	require.GreaterOrEqual(t, time.Since(start), cfg.EnqueueTimeout)
+	require.Len(t, eventSteam.idRequest, 0)
+
+	// skip the channel with full queue
+	start = time.Now()
+	require.NoError(t, eventSteam.SendRequest(ctx, []*ChannelInfo{stuck, healthy.channel}, "mock_method", parms, &mockResult{}))
+	require.Less(t, time.Since(start), cfg.EnqueueTimeout)
+
+	// closed channel fails fast instead of waiting
+	stuckCancel()
+	start = time.Now()
+	err = eventSteam.SendRequest(ctx, []*ChannelInfo{stuck}, "mock_method", parms, &mockResult{})
+	require.ErrorIs(t, err, ErrCloseChannel)
+	require.Less(t, time.Since(start), cfg.EnqueueTimeout)
+	require.NoError(t, eventSteam.SendRequest(ctx, []*ChannelInfo{stuck, healthy.channel}, "mock_method", parms, &mockResult{}))
+}
+
+func TestFanOutLeak(t *testing.T) {
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+	closedChannel := func() *ChannelInfo {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return NewChannelInfo(ctx, "127.1.1.0", make(chan *types.RequestEvent), 1)
+	}
+
+	t.Run("caller returns before response", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+		// the clients never read requests
+		silent1 := setupClient(t, eventSteam, "127.1.1.1")
+		silent2 := setupClient(t, eventSteam, "127.1.1.2")
+		defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+		callCtx, callCancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer callCancel()
+		err := eventSteam.SendRequest(callCtx, []*ChannelInfo{closedChannel(), silent1.channel, silent2.channel}, "mock_method", parms, &mockResult{})
+		require.Error(t, err)
+		require.Eventually(t, func() bool { return eventSteam.PendingRequests() == 0 }, time.Second, time.Millisecond*10)
+	})
+
+	t.Run("cancel losing attempts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+		fast := setupClient(t, eventSteam, "127.1.1.1")
+		slow := setupClient(t, eventSteam, "127.1.1.2")
+		slow.delayToReponse = time.Millisecond * 200
+		clientCtx, clientCancel := context.WithCancel(ctx)
+		go fast.start(clientCtx)
+		go slow.start(clientCtx)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, eventSteam.SendRequest(ctx, []*ChannelInfo{closedChannel(), slow.channel, fast.channel}, "mock_method", parms, &mockResult{}))
+			// the attempt to slow client is canceled without waiting for its response
+			require.Eventually(t, func() bool { return eventSteam.PendingRequests() == 0 }, time.Millisecond*150, time.Millisecond*10)
+		}
+		clientCancel()
+	})
+
+	t.Run("all request failed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, DefaultConfig())
+		defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+		err := eventSteam.SendRequest(ctx, []*ChannelInfo{closedChannel(), closedChannel(), closedChannel()}, "mock_method", parms, &mockResult{})
+		require.ErrorContains(t, err, "all request failed")
+		require.Equal(t, 0, eventSteam.PendingRequests())
+	})
+}
+
 func TestIstimeOutError(t *testing.T) {
 	err := fmt.Errorf("%w %s method %s", ErrRequestTimeout, time.Now(), "MOCK")
 	require.True(t, isTimeoutError(err))
@@ -297,6 +507,14 @@ type mockClient struct {
 	requestCh      chan *types.RequestEvent
 	channel        *ChannelInfo
 	delayToReponse time.Duration
+	// fail responds errors if set
+	fail atomic.Bool
+	// hang receives the requests without responding like a broken connection if set
+	hang atomic.Bool
+	// received counts the requests received
+	received atomic.Int32
+	// ignorePing does not respond the heartbeats like the clients of old versions
+	ignorePing bool
 
 	closeCh   chan struct{}
 	waitClose chan struct{}
@@ -312,7 +530,7 @@ func setupClient(t *testing.T, event *BaseEventStream, ip string) *mockClient {
 		t:         t,
 		requestCh: requestCh,
 		event:     event,
-		channel:   NewChannelInfo(ctx, ip, requestCh),
+		channel:   NewChannelInfo(ctx, ip, requestCh, DefaultConfig().RequestQueueSize),
 		closeCh:   make(chan struct{}),
 		waitClose: make(chan struct{}),
 		cancel:    cancel,
@@ -321,7 +539,7 @@ func setupClient(t *testing.T, event *BaseEventStream, ip string) *mockClient {
 
 func (m *mockClient) close() {
 	m.cancel()
-	close(m.requestCh)
+	m.channel.Shutdown()
 	m.closeCh <- struct{}{}
 	<-m.waitClose
 }
@@ -330,10 +548,21 @@ func (m *mockClient) start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
+			return
 		case <-m.closeCh:
 			m.waitClose <- struct{}{}
 		case req, ok := <-m.requestCh:
+			if ok && req.Method == MethodPing {
+				if !m.ignorePing {
+					require.NoError(m.t, m.event.ResponseEvent(ctx, &types.ResponseEvent{ID: req.ID}))
+				}
+				continue
+			}
 			if ok {
+				m.received.Add(1)
+				if m.hang.Load() {
+					continue
+				}
 				time.Sleep(m.delayToReponse)
 				var params mockParams
 				err := json.Unmarshal(req.Payload, &params)
@@ -344,10 +573,14 @@ func (m *mockClient) start(ctx context.Context) {
 				}
 				data, err := json.Marshal(result)
 				require.NoError(m.t, err)
+				var respErr string
+				if m.fail.Load() {
+					respErr = "mock error"
+				}
 				err = m.event.ResponseEvent(ctx, &types.ResponseEvent{
 					ID:      req.ID,
 					Payload: data,
-					Error:   "",
+					Error:   respErr,
 				})
 				require.NoError(m.t, err)
 			}
@@ -0,0 +1,214 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/tag"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+)
+
+// BreakerState is the state of the circuit breaker of a channel
+type BreakerState int
+
+const (
+	// BreakerClosed the requests are dispatched to the channel as usual
+	BreakerClosed BreakerState = iota
+	// BreakerOpen the channel failed too often, it is skipped unless no other channel is available
+	BreakerOpen
+	// BreakerHalfOpen one request is dispatched to the channel to probe whether it recovers
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerConfig struct {
+	// Window the count of the recent results to compute the failure rate, 0 means disable the breaker
+	Window int
+	// MinRequests the breaker opens only if there are at least MinRequests results in the window
+	MinRequests int
+	// FailureRate the breaker opens when the rate of the failed requests reaches it, eg. the errors responded by the client,
+	// timeout or lost by closed connections
+	FailureRate float64
+	// OpenTimeout how long the breaker keeps open before probing the channel
+	OpenTimeout time.Duration
+}
+
+var DefaultBreakerConfig = BreakerConfig{
+	Window:      20,
+	MinRequests: 5,
+	FailureRate: 0.5,
+	OpenTimeout: time.Second * 30,
+}
+
+type circuitBreaker struct {
+	lk    sync.Mutex
+	state BreakerState
+	// results is a ring of the recent results, true means failed
+	results  []bool
+	next     int
+	failures int
+	// since when the breaker is open, or the probe is dispatched
+	since   time.Time
+	probing bool
+}
+
+func (b *circuitBreaker) State() BreakerState {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.state
+}
+
+// available reports whether the requests should be dispatched to the channel now
+func (b *circuitBreaker) available(cfg BreakerConfig) bool {
+	if cfg.Window <= 0 {
+		return true
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.since) >= cfg.OpenTimeout
+	case BreakerHalfOpen:
+		// probe again if the result of the probe is lost
+		return !b.probing || time.Since(b.since) >= cfg.OpenTimeout
+	default:
+		return true
+	}
+}
+
+// dispatch makes the request the probe if the breaker is going to be half-open
+func (b *circuitBreaker) dispatch(cfg BreakerConfig) (BreakerState, bool) {
+	if cfg.Window <= 0 {
+		return BreakerClosed, false
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.state != BreakerClosed && time.Since(b.since) >= cfg.OpenTimeout || b.state == BreakerHalfOpen && !b.probing {
+		changed := b.state != BreakerHalfOpen
+		b.state = BreakerHalfOpen
+		b.probing = true
+		b.since = time.Now()
+		return b.state, changed
+	}
+	return b.state, false
+}
+
+// record returns the state after the result and whether the state changed
+func (b *circuitBreaker) record(cfg BreakerConfig, failed bool) (BreakerState, bool) {
+	if cfg.Window <= 0 {
+		return BreakerClosed, false
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.state = BreakerClosed
+		}
+		return b.state, true
+	case BreakerOpen:
+		// the requests dispatched as no other channel available, wait for probing
+		return b.state, false
+	}
+
+	if len(b.results) < cfg.Window {
+		b.results = append(b.results, failed)
+	} else {
+		if b.results[b.next] {
+			b.failures--
+		}
+		b.results[b.next] = failed
+		b.next = (b.next + 1) % len(b.results)
+	}
+	if failed {
+		b.failures++
+	}
+	if len(b.results) >= cfg.MinRequests && float64(b.failures)/float64(len(b.results)) >= cfg.FailureRate {
+		b.open()
+		return b.state, true
+	}
+	return b.state, false
+}
+
+func (b *circuitBreaker) open() {
+	b.state = BreakerOpen
+	b.since = time.Now()
+	b.results = b.results[:0]
+	b.next = 0
+	b.failures = 0
+}
+
+// BreakerState returns the state of the circuit breaker of the channel
+func (c *ChannelInfo) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+// usableChannels skips the channels with open breaker, unless all of them are open
+func (e *BaseEventStream) usableChannels(channels []*ChannelInfo) []*ChannelInfo {
+	usable := make([]*ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		if channel.breaker.available(e.cfg.Breaker) {
+			usable = append(usable, channel)
+		}
+	}
+	if len(usable) == 0 {
+		return channels
+	}
+	return usable
+}
+
+func (e *BaseEventStream) dispatchTo(channel *ChannelInfo) {
+	if state, changed := channel.breaker.dispatch(e.cfg.Breaker); changed {
+		e.breakerChanged(channel, state)
+	}
+}
+
+// recordResult counts the errors responded by the client, the timeouts and closed channels of the requests sent to it,
+// the errors caused by the caller, eg. sign rejected or unsupported method, and the requests canceled by caller are not counted
+func (e *BaseEventStream) recordResult(channel *ChannelInfo, resp *types.ResponseEvent, err error) {
+	var failed bool
+	switch {
+	case err == nil:
+		failed = resp.Error != "" && !isCallerError(resp.Error)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrCloseChannel):
+		failed = true
+	default:
+		return
+	}
+	if state, changed := channel.breaker.record(e.cfg.Breaker, failed); changed {
+		e.breakerChanged(channel, state)
+	}
+}
+
+func (e *BaseEventStream) breakerChanged(channel *ChannelInfo, state BreakerState) {
+	log.Infof("circuit breaker of channel %s(%s) is %s", channel.ChannelId, channel.Ip, state)
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.IPKey, channel.Ip), tag.Upsert(metrics.BreakerStateKey, state.String()))
+	metrics.ChannelBreaker.Tick(ctx)
+}
+
+// isCallerError reports whether the error responded by the client is caused by the request rather than the client,
+// the other clients would respond the same
+func isCallerError(respErr string) bool {
+	return strings.Contains(respErr, signRejectedPrefix) || strings.Contains(respErr, ErrUnsupportedMethod.Error())
+}
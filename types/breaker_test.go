@@ -0,0 +1,168 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg := BreakerConfig{Window: 4, MinRequests: 2, FailureRate: 0.5, OpenTimeout: time.Millisecond * 100}
+
+	t.Run("disabled", func(t *testing.T) {
+		var b circuitBreaker
+		for i := 0; i < 10; i++ {
+			b.record(BreakerConfig{}, true)
+		}
+		require.Equal(t, BreakerClosed, b.State())
+		require.True(t, b.available(BreakerConfig{}))
+	})
+
+	t.Run("open and recover", func(t *testing.T) {
+		var b circuitBreaker
+		state, changed := b.record(cfg, true)
+		require.Equal(t, BreakerClosed, state)
+		require.False(t, changed)
+		state, changed = b.record(cfg, true)
+		require.Equal(t, BreakerOpen, state)
+		require.True(t, changed)
+		require.False(t, b.available(cfg))
+
+		time.Sleep(cfg.OpenTimeout)
+		require.True(t, b.available(cfg))
+		state, changed = b.dispatch(cfg)
+		require.Equal(t, BreakerHalfOpen, state)
+		require.True(t, changed)
+		// only one probe at the same time
+		require.False(t, b.available(cfg))
+
+		state, _ = b.record(cfg, false)
+		require.Equal(t, BreakerClosed, state)
+		require.True(t, b.available(cfg))
+	})
+
+	t.Run("probe failed", func(t *testing.T) {
+		var b circuitBreaker
+		b.record(cfg, true)
+		b.record(cfg, true)
+		time.Sleep(cfg.OpenTimeout)
+		b.dispatch(cfg)
+		state, changed := b.record(cfg, true)
+		require.Equal(t, BreakerOpen, state)
+		require.True(t, changed)
+		require.False(t, b.available(cfg))
+	})
+
+	t.Run("failure rate in window", func(t *testing.T) {
+		var b circuitBreaker
+		for i := 0; i < 10; i++ {
+			b.record(cfg, false)
+		}
+		b.record(cfg, true)
+		require.Equal(t, BreakerClosed, b.State())
+		// 2 of the recent 4 results failed
+		b.record(cfg, true)
+		require.Equal(t, BreakerOpen, b.State())
+	})
+}
+
+func TestSkipBrokenChannel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Breaker = BreakerConfig{Window: 4, MinRequests: 2, FailureRate: 0.5, OpenTimeout: time.Millisecond * 200}
+	eventSteam := NewBaseEventStream(ctx, cfg)
+	broken := setupClient(t, eventSteam, "127.1.1.1")
+	broken.hang.Store(true)
+	go broken.start(ctx)
+	healthy := setupClient(t, eventSteam, "127.1.1.2")
+	go healthy.start(ctx)
+	channels := []*ChannelInfo{broken.channel, healthy.channel}
+	send := func(channels []*ChannelInfo) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer cancel()
+		return eventSteam.SendRequest(ctx, channels, "mock_method", parms, &mockResult{})
+	}
+
+	// the broken channel is picked first until the breaker opens
+	for i := 0; i < 2; i++ {
+		require.Error(t, send(channels))
+	}
+	require.Equal(t, BreakerOpen, broken.channel.BreakerState())
+	for i := 0; i < 5; i++ {
+		require.NoError(t, send(channels))
+	}
+	require.EqualValues(t, 2, broken.received.Load())
+
+	states := map[string]string{}
+	for _, channel := range channels {
+		states[channel.Ip] = channel.State("proof", "f01000").Breaker
+	}
+	require.Equal(t, map[string]string{"127.1.1.1": "open", "127.1.1.2": "closed"}, states)
+
+	// probe after OpenTimeout, and close the breaker once recovered
+	broken.hang.Store(false)
+	time.Sleep(cfg.Breaker.OpenTimeout)
+	require.NoError(t, send(channels))
+	require.EqualValues(t, 3, broken.received.Load())
+	require.Equal(t, BreakerClosed, broken.channel.BreakerState())
+
+	// use the broken channels if no other channel available
+	broken.hang.Store(true)
+	for i := 0; i < 2; i++ {
+		require.Error(t, send(channels))
+	}
+	require.Equal(t, BreakerOpen, broken.channel.BreakerState())
+	require.Error(t, send([]*ChannelInfo{broken.channel}))
+	require.EqualValues(t, 6, broken.received.Load())
+}
+
+func TestBreakerCountClientErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parms, err := json.Marshal(mockParams{A: "mock arg"})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Breaker = BreakerConfig{Window: 4, MinRequests: 2, FailureRate: 0.5, OpenTimeout: time.Minute}
+	eventSteam := NewBaseEventStream(ctx, cfg)
+	// the handler always errors over a healthy connection
+	failing := setupClient(t, eventSteam, "127.1.1.1")
+	failing.fail.Store(true)
+	go failing.start(ctx)
+	healthy := setupClient(t, eventSteam, "127.1.1.2")
+	go healthy.start(ctx)
+	channels := []*ChannelInfo{failing.channel, healthy.channel}
+
+	// the failing channel is picked first until the breaker opens
+	for i := 0; i < 2; i++ {
+		require.Error(t, eventSteam.SendRequest(ctx, channels, "mock_method", parms, &mockResult{}))
+	}
+	require.Equal(t, BreakerOpen, failing.channel.BreakerState())
+	for i := 0; i < 5; i++ {
+		require.NoError(t, eventSteam.SendRequest(ctx, channels, "mock_method", parms, &mockResult{}))
+	}
+	require.EqualValues(t, 2, failing.received.Load())
+	require.EqualValues(t, 5, healthy.received.Load())
+}
+
+func TestBreakerIgnoreCallerErrors(t *testing.T) {
+	channel := NewChannelInfo(context.Background(), "127.1.1.1", make(chan *types.RequestEvent), 1)
+	eventSteam := NewBaseEventStream(context.Background(), DefaultConfig())
+	eventSteam.cfg.Breaker = BreakerConfig{Window: 4, MinRequests: 2, FailureRate: 0.5, OpenTimeout: time.Minute}
+
+	rejected := (&SignRejectedError{Reason: "mock reason"}).Error()
+	for _, respErr := range []string{rejected, ErrUnsupportedMethod.Error(), rejected, ErrUnsupportedMethod.Error()} {
+		eventSteam.recordResult(channel, &types.ResponseEvent{Error: respErr}, nil)
+	}
+	require.Equal(t, BreakerClosed, channel.BreakerState())
+}
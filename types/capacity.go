@@ -0,0 +1,88 @@
+package types
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// ChannelCapacity is advertised by clients on registration through the extension api of sophon-gateway
+type ChannelCapacity struct {
+	// MaxConcurrent limits the requests dispatched to the channel at the same time, 0 means unlimited
+	MaxConcurrent int
+	// ProofTypes are the proof types supported by the proof client, empty means all
+	ProofTypes []abi.RegisteredPoStProof
+}
+
+// Inflight returns the number of requests dispatched to the channel and not responded
+func (c *ChannelInfo) Inflight() int {
+	return int(c.inflight.Load())
+}
+
+func (c *ChannelInfo) tryAcquire() bool {
+	for {
+		n := c.inflight.Load()
+		if c.Capacity.MaxConcurrent > 0 && n >= int64(c.Capacity.MaxConcurrent) {
+			return false
+		}
+		if c.inflight.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (c *ChannelInfo) release() {
+	c.inflight.Add(-1)
+}
+
+// acquireChannel returns the first channel with free capacity and closed breaker, waits if all the channels are busy
+func (e *BaseEventStream) acquireChannel(ctx context.Context, channels []*ChannelInfo) (*ChannelInfo, error) {
+	for {
+		e.slotLk.Lock()
+		slotFreed := e.slotFreed
+		e.slotLk.Unlock()
+
+		usable := e.usableChannels(channels)
+		if channel := acquireFirst(usable); channel != nil {
+			e.dispatchTo(channel)
+			return channel, nil
+		}
+
+		log.Debugf("all of %d channels are busy, wait for free capacity", len(channels))
+		select {
+		case <-slotFreed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for free channel cancel by context %w", ctx.Err())
+		}
+	}
+}
+
+func acquireFirst(channels []*ChannelInfo) *ChannelInfo {
+	for _, channel := range channels {
+		if channel.Ctx.Err() == nil && !channel.queue.full() && channel.tryAcquire() {
+			return channel
+		}
+	}
+	// every open channel is busy or has a full queue, the request waits for free space of the queue
+	for _, channel := range channels {
+		if channel.Ctx.Err() == nil && channel.tryAcquire() {
+			return channel
+		}
+	}
+	// the request sent to a closed channel fails immediately and then tries the others
+	for _, channel := range channels {
+		if channel.Ctx.Err() != nil && channel.tryAcquire() {
+			return channel
+		}
+	}
+	return nil
+}
+
+func (e *BaseEventStream) releaseChannel(channel *ChannelInfo) {
+	channel.release()
+	e.slotLk.Lock()
+	defer e.slotLk.Unlock()
+	close(e.slotFreed)
+	e.slotFreed = make(chan struct{})
+}
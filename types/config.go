@@ -8,13 +8,42 @@ type RequestConfig struct {
 	RequestQueueSize int
 	RequestTimeout   time.Duration
 	ClearInterval    time.Duration
+	// ValidateCaller check whether the miner of the request belongs to the caller
+	ValidateCaller bool
+	// PieceChunkSize the bytes of a piece read from a market client by one request, at most MaxPieceChunkSize
+	PieceChunkSize uint64
+	// MaxPieceReadSize the max bytes of a piece range read by one ReadPiece call, 0 means no limit
+	MaxPieceReadSize uint64
+	// WalletProbe asks the connected wallets to list their addresses again when the address is not found,
+	// so that the keys imported after connected can be used without reconnecting
+	WalletProbe bool
+	// WalletProbeInterval the min interval to probe the same wallet connection
+	WalletProbeInterval time.Duration
+	// EnqueueTimeout how long to wait for free space when the request queue of a channel is full,
+	// the request is sent to other channels then, 0 means not to wait
+	EnqueueTimeout time.Duration
+	// Breaker skips the channels failing repeatedly
+	Breaker BreakerConfig
+	// Heartbeat pings the clients to close the dead connections
+	Heartbeat HeartbeatConfig
 }
 
+const (
+	DefaultPieceChunkSize   = 1 << 20
+	DefaultMaxPieceReadSize = 1 << 30
+)
+
 func DefaultConfig() *RequestConfig {
 	return &RequestConfig{
-		RequestQueueSize: 30,
-		RequestTimeout:   time.Minute * 5,
-		ClearInterval:    time.Minute * 5,
+		RequestQueueSize:    30,
+		RequestTimeout:      time.Minute * 5,
+		ClearInterval:       time.Minute * 5,
+		EnqueueTimeout:      time.Second * 5,
+		PieceChunkSize:      DefaultPieceChunkSize,
+		MaxPieceReadSize:    DefaultMaxPieceReadSize,
+		WalletProbeInterval: time.Second * 10,
+		Breaker:             DefaultBreakerConfig,
+		Heartbeat:           DefaultHeartbeatConfig,
 	}
 }
 
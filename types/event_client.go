@@ -0,0 +1,182 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+)
+
+// RequestHandler handles the payload of a request pushed by gateway, the result is responded to gateway
+type RequestHandler func(ctx context.Context, payload []byte) (interface{}, error)
+
+// TypedHandler decodes the payload as Req for h
+func TypedHandler[Req any, Resp any](h func(ctx context.Context, req Req) (Resp, error)) RequestHandler {
+	return func(ctx context.Context, payload []byte) (interface{}, error) {
+		var req Req
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return h(ctx, req)
+	}
+}
+
+// EventClient receives the requests pushed by gateway and dispatches them to the handlers by method,
+// the clients of the push services share it and register only the typed handlers
+type EventClient struct {
+	log         *zap.SugaredLogger
+	reconnector *Reconnector
+	pool        *WorkerPool
+	handlers    map[string]RequestHandler
+	respond     func(ctx context.Context, resp *types.ResponseEvent) error
+
+	lk      sync.Mutex
+	channel sharedTypes.UUID
+}
+
+func NewEventClient(log *zap.SugaredLogger, respond func(ctx context.Context, resp *types.ResponseEvent) error) *EventClient {
+	return &EventClient{
+		log:         log,
+		reconnector: NewReconnector(DefaultReconnectConfig(), log),
+		pool:        NewWorkerPool(DefaultWorkerPoolConfig),
+		handlers:    make(map[string]RequestHandler),
+		respond:     respond,
+	}
+}
+
+// Handle must be called before listening
+func (c *EventClient) Handle(method string, h RequestHandler) {
+	c.handlers[method] = h
+}
+
+// SetReconnectConfig must be called before listening
+func (c *EventClient) SetReconnectConfig(cfg ReconnectConfig) {
+	c.reconnector = NewReconnector(cfg, c.log)
+}
+
+// SetWorkerPool must be called before listening, a pool can be shared by several clients
+func (c *EventClient) SetWorkerPool(pool *WorkerPool) {
+	c.pool = pool
+}
+
+func (c *EventClient) WaitReady(ctx context.Context) {
+	c.reconnector.WaitReady(ctx)
+}
+
+// ChannelID returns the id of the current connection assigned by gateway
+func (c *EventClient) ChannelID() sharedTypes.UUID {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.channel
+}
+
+// Run keeps listening and reconnects once disconnected
+func (c *EventClient) Run(ctx context.Context, listen func(ctx context.Context) (<-chan *types.RequestEvent, error)) error {
+	return c.reconnector.Run(ctx, func(ctx context.Context) error {
+		return c.ListenOnce(ctx, listen)
+	})
+}
+
+// ListenOnce handles the requests until disconnected, the errors of listen are returned as is
+func (c *EventClient) ListenOnce(ctx context.Context, listen func(ctx context.Context) (<-chan *types.RequestEvent, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := listen(ctx)
+	if err != nil {
+		// Retry is handled by caller
+		return err
+	}
+
+	for event := range events {
+		switch event.Method {
+		case "InitConnect":
+			req := types.ConnectedCompleted{}
+			if err := json.Unmarshal(event.Payload, &req); err != nil {
+				return fmt.Errorf("odd error in connect %v", err)
+			}
+			c.lk.Lock()
+			c.channel = req.ChannelId
+			c.lk.Unlock()
+			c.reconnector.Connected(req.ChannelId)
+			c.log.Infof("success to connect with gateway %s", req.ChannelId)
+			// do not response
+		case MethodPing:
+			go c.Value(ctx, event.ID, nil)
+		case MethodProtocol:
+			go c.Value(ctx, event.ID, c.protocol())
+		case MethodReconnect:
+			req := ReconnectRequest{}
+			_ = json.Unmarshal(event.Payload, &req)
+			c.log.Warnf("gateway asks to reconnect: %s", req.Reason)
+			return nil
+		default:
+			h, ok := c.handlers[event.Method]
+			if !ok {
+				c.log.Errorf("unexpect event type %s", event.Method)
+				go c.Error(ctx, event.ID, fmt.Errorf("%w %s", ErrUnsupportedMethod, event.Method))
+				continue
+			}
+			id, method, payload := event.ID, event.Method, event.Payload
+			if err := c.pool.Submit(ctx, func(ctx context.Context) {
+				result, err := h(ctx, payload)
+				if err != nil {
+					c.log.Errorf("handle %s error %s", method, err)
+					c.Error(ctx, id, err)
+					return
+				}
+				c.Value(ctx, id, result)
+			}); err != nil {
+				c.Error(ctx, id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// protocol advertises the methods of the registered handlers and the control methods handled by the client
+func (c *EventClient) protocol() *ClientProtocol {
+	methods := make([]string, 0, len(c.handlers)+2)
+	methods = append(methods, MethodPing, MethodReconnect)
+	for method := range c.handlers {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	return &ClientProtocol{Version: ProtocolVersion, Methods: methods}
+}
+
+// Value responds the result of request id
+func (c *EventClient) Value(ctx context.Context, id sharedTypes.UUID, val interface{}) {
+	respBytes, err := json.Marshal(val)
+	if err != nil {
+		c.log.Errorf("marshal response error %s", err)
+		c.Error(ctx, id, err)
+		return
+	}
+	err = c.respond(ctx, &types.ResponseEvent{
+		ID:      id,
+		Payload: respBytes,
+		Error:   "",
+	})
+	if err != nil {
+		c.log.Errorf("response error %v", err)
+	}
+}
+
+// Error responds the error of request id
+func (c *EventClient) Error(ctx context.Context, id sharedTypes.UUID, err error) {
+	err = c.respond(ctx, &types.ResponseEvent{
+		ID:      id,
+		Payload: nil,
+		Error:   err.Error(),
+	})
+	if err != nil {
+		c.log.Errorf("response error %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opencensus.io/tag"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+	"github.com/ipfs-force-community/sophon-gateway/validator"
+)
+
+// ChannelStore indexes the channels by the key they registered with, eg. the miner address or wallet account
+type ChannelStore[K comparable] struct {
+	lk       sync.RWMutex
+	channels map[K]map[sharedTypes.UUID]*ChannelInfo
+}
+
+func NewChannelStore[K comparable]() *ChannelStore[K] {
+	return &ChannelStore[K]{
+		channels: make(map[K]map[sharedTypes.UUID]*ChannelInfo),
+	}
+}
+
+func (cs *ChannelStore[K]) Add(key K, channel *ChannelInfo) {
+	cs.lk.Lock()
+	defer cs.lk.Unlock()
+	channels, ok := cs.channels[key]
+	if !ok {
+		channels = make(map[sharedTypes.UUID]*ChannelInfo)
+		cs.channels[key] = channels
+	}
+	channels[channel.ChannelId] = channel
+}
+
+// Remove returns false if the channel is not in the store
+func (cs *ChannelStore[K]) Remove(key K, channelID sharedTypes.UUID) bool {
+	cs.lk.Lock()
+	defer cs.lk.Unlock()
+	channels, ok := cs.channels[key]
+	if !ok {
+		return false
+	}
+	if _, ok := channels[channelID]; !ok {
+		return false
+	}
+	delete(channels, channelID)
+	if len(channels) == 0 {
+		delete(cs.channels, key)
+	}
+	return true
+}
+
+func (cs *ChannelStore[K]) Get(key K) []*ChannelInfo {
+	cs.lk.RLock()
+	defer cs.lk.RUnlock()
+	channels := make([]*ChannelInfo, 0, len(cs.channels[key]))
+	for _, channel := range cs.channels[key] {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (cs *ChannelStore[K]) Has(key K) bool {
+	cs.lk.RLock()
+	defer cs.lk.RUnlock()
+	_, ok := cs.channels[key]
+	return ok
+}
+
+func (cs *ChannelStore[K]) Keys() []K {
+	cs.lk.RLock()
+	defer cs.lk.RUnlock()
+	keys := make([]K, 0, len(cs.channels))
+	for key := range cs.channels {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Find returns the channel of channelID and the key it registered with
+func (cs *ChannelStore[K]) Find(channelID sharedTypes.UUID) (K, *ChannelInfo, bool) {
+	cs.lk.RLock()
+	defer cs.lk.RUnlock()
+	for key, channels := range cs.channels {
+		if channel, ok := channels[channelID]; ok {
+			return key, channel, true
+		}
+	}
+	var key K
+	return key, nil, false
+}
+
+// All returns a snapshot of the channels of each key
+func (cs *ChannelStore[K]) All() map[K][]*ChannelInfo {
+	cs.lk.RLock()
+	defer cs.lk.RUnlock()
+	all := make(map[K][]*ChannelInfo, len(cs.channels))
+	for key, channels := range cs.channels {
+		for _, channel := range channels {
+			all[key] = append(all[key], channel)
+		}
+	}
+	return all
+}
+
+type EventServiceConfig[K comparable] struct {
+	// Kind names the service in the logs, metrics and channel states, eg. proof
+	Kind string
+	// KeyName names the key in the errors, eg. miner
+	KeyName string
+	// Validate checks whether the caller is allowed to register with key, it is also used to revalidate the channels
+	Validate func(ctx context.Context, key K) error
+	// OnAdded is called once the channel is visible to the requests
+	OnAdded func(key K, channel *ChannelInfo)
+	// OnRemoved is called once the channel is removed
+	OnRemoved func(key K, channel *ChannelInfo)
+	// LegacyMethods are handled by the legacy clients not advertising the protocol, the other methods are routed
+	// to a channel only after the client advertised them
+	LegacyMethods []string
+}
+
+type RegisterOptions struct {
+	// Capacity is nil for the clients not aware of it
+	Capacity *ChannelCapacity
+	// Prepare is called in background before the channel is visible to the requests, eg. to query the client,
+	// the channel is closed if it fails
+	Prepare func(ctx context.Context, channel *ChannelInfo) error
+}
+
+// EventService pushes requests to the clients registered with a key and routes the requests by the key,
+// the push services share it and implement only the typed methods
+type EventService[K comparable] struct {
+	*BaseEventStream
+	store  *ChannelStore[K]
+	cfg    EventServiceConfig[K]
+	reqCfg *RequestConfig
+}
+
+func NewEventService[K comparable](ctx context.Context, cfg EventServiceConfig[K], reqCfg *RequestConfig) *EventService[K] {
+	return &EventService[K]{
+		BaseEventStream: NewBaseEventStream(ctx, reqCfg),
+		store:           NewChannelStore[K](),
+		cfg:             cfg,
+		reqCfg:          reqCfg,
+	}
+}
+
+// Register creates a channel of the client with key, the requests are pushed to the returned chan until ctx done
+// or the channel is disconnected
+func (s *EventService[K]) Register(ctx context.Context, key K, ip string, opts RegisterOptions) (<-chan *types.RequestEvent, error) {
+	if opts.Capacity != nil && opts.Capacity.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("invalid max concurrent %d", opts.Capacity.MaxConcurrent)
+	}
+	if s.IsDraining() {
+		return nil, ErrDraining
+	}
+	if s.cfg.Validate != nil {
+		if err := s.cfg.Validate(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	// requests wait in the priority queue of channel instead of the buffer of out
+	out := make(chan *types.RequestEvent)
+	channel := NewChannelInfo(ctx, ip, out, s.reqCfg.RequestQueueSize)
+	if opts.Capacity != nil {
+		channel.Capacity = *opts.Capacity
+	}
+	channel.restrictLegacy(s.cfg.LegacyMethods)
+	if opts.Prepare == nil {
+		s.serve(key, channel)
+		return out, nil
+	}
+
+	go func() {
+		if err := opts.Prepare(channel.Ctx, channel); err != nil {
+			log.Errorf("prepare connection %s for %s %v failed: %v", channel.ChannelId, s.cfg.Kind, key, err)
+			channel.Shutdown()
+			return
+		}
+		s.serve(key, channel)
+	}()
+	return out, nil
+}
+
+func (s *EventService[K]) serve(key K, channel *ChannelInfo) {
+	// enqueue before the channel is visible, so that the client receives InitConnect first
+	if err := s.initConnect(channel); err != nil {
+		log.Errorf("init connection %s for %s %v failed: %v", channel.ChannelId, s.cfg.Kind, key, err)
+		channel.Shutdown()
+		return
+	}
+
+	s.store.Add(key, channel)
+	log.Infof("add new connections %s for %s %v", channel.ChannelId, s.cfg.Kind, key)
+	if s.cfg.OnAdded != nil {
+		s.cfg.OnAdded(key, channel)
+	}
+	s.StartHeartbeat(channel, s.cfg.Kind)
+	go s.negotiate(channel)
+	go func() {
+		<-channel.Ctx.Done()
+		s.remove(key, channel)
+		// the channel may be disconnected while the client is not reading, out is closed after no more writes
+		channel.Shutdown()
+	}()
+}
+
+func (s *EventService[K]) initConnect(channel *ChannelInfo) error {
+	connectBytes, err := json.Marshal(types.ConnectedCompleted{
+		ChannelId: channel.ChannelId,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal failed %w", err)
+	}
+	if !channel.Enqueue(&types.RequestEvent{
+		ID:         sharedTypes.NewUUID(),
+		Method:     "InitConnect",
+		Payload:    connectBytes,
+		CreateTime: time.Now(),
+		Result:     nil,
+	}, PriorityControl) { // no response
+		return ErrQueueFull
+	}
+	return nil
+}
+
+func (s *EventService[K]) remove(key K, channel *ChannelInfo) {
+	if !s.store.Remove(key, channel.ChannelId) {
+		return
+	}
+	log.Infof("remove connections %s of %s %v", channel.ChannelId, s.cfg.Kind, key)
+	if s.cfg.OnRemoved != nil {
+		s.cfg.OnRemoved(key, channel)
+	}
+}
+
+func (s *EventService[K]) disconnect(key K, channel *ChannelInfo) {
+	channel.Close()
+	s.remove(key, channel)
+}
+
+// Channels routes the requests of key to its channels
+func (s *EventService[K]) Channels(key K) ([]*ChannelInfo, error) {
+	channels := s.store.Get(key)
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no connections for this %s %v", s.cfg.KeyName, key)
+	}
+	return channels, nil
+}
+
+// Has reports whether a client of key is connected to this gateway
+func (s *EventService[K]) Has(key K) bool {
+	return s.store.Has(key)
+}
+
+// Keys returns the keys of the connected clients
+func (s *EventService[K]) Keys() []K {
+	return s.store.Keys()
+}
+
+// ConnectStates returns the connections of key, false if no connection
+func (s *EventService[K]) ConnectStates(key K) (*types.ConnectionStates, bool) {
+	channels := s.store.Get(key)
+	if len(channels) == 0 {
+		return nil, false
+	}
+	return connectStates(channels), true
+}
+
+// AllConnectStates returns the connections of each key
+func (s *EventService[K]) AllConnectStates() map[K]*types.ConnectionStates {
+	all := s.store.All()
+	states := make(map[K]*types.ConnectionStates, len(all))
+	for key, channels := range all {
+		states[key] = connectStates(channels)
+	}
+	return states
+}
+
+func connectStates(channels []*ChannelInfo) *types.ConnectionStates {
+	states := &types.ConnectionStates{}
+	for _, channel := range channels {
+		states.ConnectionCount++
+		states.Connections = append(states.Connections, &types.ConnectState{
+			ChannelID:    channel.ChannelId,
+			RequestCount: channel.Inflight(),
+			IP:           channel.Ip,
+			CreateTime:   channel.CreateTime,
+		})
+	}
+	return states
+}
+
+// ListChannelStates returns the states of all the connections
+func (s *EventService[K]) ListChannelStates(ctx context.Context) ([]*ChannelState, error) {
+	var states []*ChannelState
+	for key, channels := range s.store.All() {
+		for _, channel := range channels {
+			states = append(states, channel.State(s.cfg.Kind, fmt.Sprint(key)))
+		}
+	}
+	return states, nil
+}
+
+// DisconnectChannel closes the connection of channelID and fails the requests waiting on it,
+// returns false if no such connection
+func (s *EventService[K]) DisconnectChannel(ctx context.Context, channelID sharedTypes.UUID) bool {
+	key, channel, ok := s.store.Find(channelID)
+	if !ok {
+		return false
+	}
+	s.disconnect(key, channel)
+	return true
+}
+
+// DisconnectKey closes all the connections of key
+func (s *EventService[K]) DisconnectKey(key K) error {
+	channels, err := s.Channels(key)
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		s.disconnect(key, channel)
+	}
+	return nil
+}
+
+// Drain stops accepting new connections and requests, waits for the outstanding requests to complete until ctx done,
+// then asks all the clients to connect to other gateways
+func (s *EventService[K]) Drain(ctx context.Context) error {
+	err := s.BaseEventStream.Drain(ctx)
+	for _, channels := range s.store.All() {
+		for _, channel := range channels {
+			s.NotifyReconnect(channel, ErrDraining.Error())
+		}
+	}
+	return err
+}
+
+// StartRevalidate re-validates the token and key of each connection every interval,
+// and closes the connections no longer authorized.
+func (s *EventService[K]) StartRevalidate(ctx context.Context, tokenValidator validator.ITokenValidator, interval time.Duration) {
+	go func() {
+		tm := time.NewTicker(interval)
+		defer tm.Stop()
+		for {
+			select {
+			case <-tm.C:
+				s.Revalidate(tokenValidator)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Revalidate re-validates all the connections once
+func (s *EventService[K]) Revalidate(tokenValidator validator.ITokenValidator) {
+	for key, channels := range s.store.All() {
+		for _, channel := range channels {
+			reason, err := validator.Revalidate(channel.Ctx, tokenValidator, func(ctx context.Context) error {
+				if s.cfg.Validate == nil {
+					return nil
+				}
+				return s.cfg.Validate(ctx, key)
+			})
+			if err == nil {
+				continue
+			}
+
+			log.Warnf("evict connection %s of %s %v, %s is no longer valid: %v", channel.ChannelId, s.cfg.Kind, key, reason, err)
+			ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.ChannelTypeKey, s.cfg.Kind), tag.Upsert(metrics.EvictReasonKey, reason))
+			metrics.ChannelEvict.Tick(ctx)
+			s.disconnect(key, channel)
+		}
+	}
+}
+
+// Call sends req to one of channels and decodes the response as Resp
+func Call[Resp any](ctx context.Context, s *BaseEventStream, channels []*ChannelInfo, method string, priority Priority, req interface{}) (Resp, error) {
+	var resp Resp
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	err = s.SendRequestWithPriority(ctx, channels, method, payload, priority, &resp)
+	return resp, err
+}
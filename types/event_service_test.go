@@ -0,0 +1,234 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+)
+
+func TestChannelStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newChannel := func() *ChannelInfo {
+		return NewChannelInfo(ctx, "127.1.1.1", make(chan *types.RequestEvent), 1)
+	}
+
+	store := NewChannelStore[string]()
+	ch1, ch2, ch3 := newChannel(), newChannel(), newChannel()
+	store.Add("a", ch1)
+	store.Add("a", ch2)
+	store.Add("b", ch3)
+	require.True(t, store.Has("a"))
+	require.Len(t, store.Get("a"), 2)
+	require.ElementsMatch(t, []string{"a", "b"}, store.Keys())
+
+	key, channel, ok := store.Find(ch3.ChannelId)
+	require.True(t, ok)
+	require.Equal(t, "b", key)
+	require.Equal(t, ch3, channel)
+	_, _, ok = store.Find(sharedTypes.NewUUID())
+	require.False(t, ok)
+
+	require.True(t, store.Remove("b", ch3.ChannelId))
+	require.False(t, store.Remove("b", ch3.ChannelId))
+	require.False(t, store.Has("b"))
+	require.Len(t, store.All()["a"], 2)
+}
+
+type echoRequest struct {
+	Msg string
+}
+
+func TestEventService(t *testing.T) {
+	var allowed atomic.Bool
+	allowed.Store(true)
+	setup := func(ctx context.Context, removed *atomic.Int32) *EventService[string] {
+		return NewEventService(ctx, EventServiceConfig[string]{
+			Kind:          "echo",
+			KeyName:       "user",
+			LegacyMethods: []string{"Echo"},
+			Validate: func(ctx context.Context, key string) error {
+				if !allowed.Load() {
+					return fmt.Errorf("user %s is not allowed", key)
+				}
+				return nil
+			},
+			OnRemoved: func(key string, channel *ChannelInfo) {
+				if removed != nil {
+					removed.Add(1)
+				}
+			},
+		}, DefaultConfig())
+	}
+	listen := func(ctx context.Context, svc *EventService[string], opts RegisterOptions) (*EventClient, chan error) {
+		client := NewEventClient(zap.NewNop().Sugar(), svc.ResponseEvent)
+		client.Handle("Echo", TypedHandler(func(ctx context.Context, req echoRequest) (string, error) {
+			return req.Msg, nil
+		}))
+		done := make(chan error, 1)
+		go func() {
+			done <- client.ListenOnce(ctx, func(ctx context.Context) (<-chan *types.RequestEvent, error) {
+				return svc.Register(ctx, "user1", "127.1.1.1", opts)
+			})
+		}()
+		return client, done
+	}
+
+	t.Run("route typed request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		client, _ := listen(ctx, svc, RegisterOptions{})
+		client.WaitReady(ctx)
+
+		channels, err := svc.Channels("user1")
+		require.NoError(t, err)
+		require.Len(t, channels, 1)
+		require.Equal(t, channels[0].ChannelId, client.ChannelID())
+		resp, err := Call[string](ctx, svc.BaseEventStream, channels, "Echo", PriorityDefault, echoRequest{Msg: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "hello", resp)
+
+		_, err = svc.Channels("user2")
+		require.Contains(t, err.Error(), "no connections for this user user2")
+		states, err := svc.ListChannelStates(ctx)
+		require.NoError(t, err)
+		require.Len(t, states, 1)
+		require.Equal(t, "echo", states[0].Type)
+		require.Equal(t, "user1", states[0].Owner)
+	})
+
+	t.Run("negotiate protocol", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		client, _ := listen(ctx, svc, RegisterOptions{})
+		client.WaitReady(ctx)
+
+		channels, err := svc.Channels("user1")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return channels[0].Protocol() != nil }, time.Second*5, time.Millisecond*10)
+		require.Equal(t, &ClientProtocol{Version: ProtocolVersion, Methods: []string{"Echo", MethodPing, MethodReconnect}}, channels[0].Protocol())
+		states, err := svc.ListChannelStates(ctx)
+		require.NoError(t, err)
+		require.Equal(t, ProtocolVersion, states[0].Version)
+
+		// not routed to the client
+		_, err = Call[string](ctx, svc.BaseEventStream, channels, "Sign", PriorityDefault, echoRequest{})
+		require.True(t, IsUnsupportedMethod(err))
+		require.Contains(t, err.Error(), "by the clients")
+		require.Equal(t, 0, svc.PendingRequests())
+	})
+
+	t.Run("legacy client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		out, err := svc.Register(ctx, "user1", "127.1.1.1", RegisterOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "InitConnect", (<-out).Method)
+		req := <-out
+		require.Equal(t, MethodProtocol, req.Method)
+		require.NoError(t, svc.ResponseEvent(ctx, &types.ResponseEvent{ID: req.ID, Error: "unexpect event type Protocol"}))
+
+		channels, err := svc.Channels("user1")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return svc.PendingRequests() == 0 }, time.Second*5, time.Millisecond*10)
+		require.Nil(t, channels[0].Protocol())
+		require.True(t, channels[0].Supports("Echo"))
+		require.False(t, channels[0].Supports("Sign"))
+		require.False(t, channels[0].Supports(MethodPing))
+	})
+
+	t.Run("client not answering protocol", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		timeout := ProtocolNegotiateTimeout
+		ProtocolNegotiateTimeout = time.Millisecond * 200
+		defer func() { ProtocolNegotiateTimeout = timeout }()
+		svc := setup(ctx, nil)
+		out, err := svc.Register(ctx, "user1", "127.1.1.1", RegisterOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "InitConnect", (<-out).Method)
+		require.Equal(t, MethodProtocol, (<-out).Method)
+
+		channels, err := svc.Channels("user1")
+		require.NoError(t, err)
+		// the new method waits for the negotiation rather than the request timeout, then is not routed
+		start := time.Now()
+		_, err = Call[string](ctx, svc.BaseEventStream, channels, "Sign", PriorityDefault, echoRequest{})
+		require.True(t, IsUnsupportedMethod(err))
+		require.Less(t, time.Since(start), time.Second*5)
+		require.Equal(t, 0, svc.PendingRequests())
+		require.Nil(t, channels[0].Protocol())
+		require.Equal(t, BreakerClosed, channels[0].breaker.State())
+	})
+
+	t.Run("reject invalid key", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		allowed.Store(false)
+		defer allowed.Store(true)
+		_, err := svc.Register(ctx, "user1", "127.1.1.1", RegisterOptions{})
+		require.Contains(t, err.Error(), "is not allowed")
+	})
+
+	t.Run("disconnect key", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var removed atomic.Int32
+		svc := setup(ctx, &removed)
+		client, done := listen(ctx, svc, RegisterOptions{})
+		client.WaitReady(ctx)
+
+		require.NoError(t, svc.DisconnectKey("user1"))
+		require.False(t, svc.Has("user1"))
+		require.Error(t, svc.DisconnectKey("user1"))
+		require.NoError(t, <-done)
+		require.Equal(t, int32(1), removed.Load())
+	})
+
+	t.Run("prepare failed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		_, done := listen(ctx, svc, RegisterOptions{
+			Prepare: func(ctx context.Context, channel *ChannelInfo) error {
+				return fmt.Errorf("mock prepare error")
+			},
+		})
+		require.NoError(t, <-done)
+		require.False(t, svc.Has("user1"))
+	})
+
+	t.Run("evict revoked key", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		svc := setup(ctx, nil)
+		client, done := listen(ctx, svc, RegisterOptions{})
+		client.WaitReady(ctx)
+
+		svc.Revalidate(nil)
+		require.True(t, svc.Has("user1"))
+		allowed.Store(false)
+		defer allowed.Store(true)
+		svc.Revalidate(nil)
+		require.False(t, svc.Has("user1"))
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second * 5):
+			t.Errorf("unable to wait for closed channel within 5s")
+		}
+	})
+}
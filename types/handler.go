@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"io"
 
 	"github.com/ipfs/go-cid"
 
@@ -22,6 +23,14 @@ type MarketHandler interface {
 	SectorsUnsealPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset types.UnpaddedByteIndex, size abi.UnpaddedPieceSize, dest string) error
 }
 
+// MarketRetrievalHandler is optionally implemented by the MarketHandler to serve the retrieval requests,
+// the requests are rejected by the clients whose handler doesn't implement it
+type MarketRetrievalHandler interface {
+	IsUnsealed(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset types.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (bool, error)
+	LocatePiece(ctx context.Context, miner address.Address, pieceCid cid.Cid) ([]PieceLocation, error)
+	ReadPiece(ctx context.Context, miner address.Address, pieceCid cid.Cid, sid abi.SectorNumber, offset types.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (io.ReadCloser, error)
+}
+
 type IWalletHandler interface {
 	WalletList(ctx context.Context) ([]address.Address, error)
 	WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta types.MsgMeta) (*crypto.Signature, error)
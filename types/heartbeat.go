@@ -0,0 +1,106 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opencensus.io/tag"
+
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+)
+
+// MethodPing is sent to clients periodically, clients should respond it immediately with an empty payload
+const MethodPing = "Ping"
+
+type HeartbeatConfig struct {
+	// Interval the interval to ping the clients, 0 means disable
+	Interval time.Duration
+	// MaxMisses the channel is closed after missing MaxMisses heartbeats in a row
+	MaxMisses int
+}
+
+var DefaultHeartbeatConfig = HeartbeatConfig{
+	Interval:  time.Second * 30,
+	MaxMisses: 3,
+}
+
+// LastSeen returns when the last response of the client was received, zero if never
+func (c *ChannelInfo) LastSeen() time.Time {
+	nano := c.lastSeen.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// RTT returns the round trip time of the last heartbeat
+func (c *ChannelInfo) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+func (c *ChannelInfo) touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+// StartHeartbeat pings the client of channel until the channel closed, and closes the channel if the client misses
+// too many heartbeats. Only the clients advertising Ping in their protocol are pinged, the liveness of the legacy
+// clients is left to the websocket, the clients never responding the ping are not closed either.
+func (e *BaseEventStream) StartHeartbeat(channel *ChannelInfo, kind string) {
+	cfg := e.cfg.Heartbeat
+	if cfg.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		// whether the client responds the ping
+		alive := false
+		misses := 0
+		for {
+			select {
+			case <-ticker.C:
+			case <-channel.Ctx.Done():
+				return
+			}
+			if !channel.Supports(MethodPing) {
+				continue
+			}
+
+			if err := e.ping(channel, cfg.Interval); err != nil {
+				if channel.Ctx.Err() != nil {
+					return
+				}
+				misses++
+				log.Debugf("channel %s(%s) missed %d heartbeats: %v", channel.ChannelId, channel.Ip, misses, err)
+				if alive && cfg.MaxMisses > 0 && misses >= cfg.MaxMisses {
+					log.Warnf("close channel %s(%s) as missed %d heartbeats, last seen at %s", channel.ChannelId, channel.Ip, misses, channel.LastSeen())
+					ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.ChannelTypeKey, kind), tag.Upsert(metrics.EvictReasonKey, "heartbeat"))
+					metrics.ChannelEvict.Tick(ctx)
+					channel.Close()
+					return
+				}
+				continue
+			}
+			alive = true
+			misses = 0
+		}
+	}()
+}
+
+func (e *BaseEventStream) ping(channel *ChannelInfo, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(channel.Ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := e.sendOnce(ctx, channel, MethodPing, nil, PriorityControl)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) > 0 {
+		return fmt.Errorf("client responds error: %s", resp.Error)
+	}
+	channel.rtt.Store(int64(time.Since(start)))
+	return nil
+}
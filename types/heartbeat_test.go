@@ -0,0 +1,71 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Heartbeat = HeartbeatConfig{Interval: time.Millisecond * 50, MaxMisses: 2}
+
+	t.Run("evict dead client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, cfg)
+		client := setupClient(t, eventSteam, "127.1.1.1")
+		clientCtx, clientCancel := context.WithCancel(ctx)
+		go client.start(clientCtx)
+
+		eventSteam.StartHeartbeat(client.channel, "proof")
+		require.Eventually(t, func() bool { return !client.channel.LastSeen().IsZero() }, time.Second, time.Millisecond*10)
+		require.Greater(t, client.channel.RTT(), time.Duration(0))
+		state := client.channel.State("proof", "f01000")
+		require.Equal(t, client.channel.LastSeen(), state.LastSeen)
+
+		// the client stops reading like a half-open connection
+		clientCancel()
+		require.Eventually(t, func() bool { return client.channel.Ctx.Err() != nil }, time.Second*2, time.Millisecond*10)
+		require.Equal(t, 0, eventSteam.PendingRequests())
+	})
+
+	t.Run("keep client not responding ping", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, cfg)
+		client := setupClient(t, eventSteam, "127.1.1.1")
+		client.ignorePing = true
+		go client.start(ctx)
+
+		eventSteam.StartHeartbeat(client.channel, "proof")
+		time.Sleep(cfg.Heartbeat.Interval * 6)
+		require.NoError(t, client.channel.Ctx.Err())
+		require.True(t, client.channel.LastSeen().IsZero())
+		require.Equal(t, BreakerClosed, client.channel.BreakerState())
+	})
+	t.Run("skip legacy client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		eventSteam := NewBaseEventStream(ctx, cfg)
+		client := setupClient(t, eventSteam, "127.1.1.1")
+		client.channel.restrictLegacy([]string{"ComputeProof"})
+		close(client.channel.negotiated)
+
+		eventSteam.StartHeartbeat(client.channel, "proof")
+		select {
+		case req := <-client.requestCh:
+			t.Fatalf("unexpected request %s to legacy client", req.Method)
+		case <-time.After(cfg.Heartbeat.Interval * 4):
+		}
+		require.NoError(t, client.channel.Ctx.Err())
+
+		// pinged once the client advertised Ping
+		client.channel.protocol.Store(&ClientProtocol{Version: ProtocolVersion, Methods: []string{MethodPing}})
+		go client.start(ctx)
+		require.Eventually(t, func() bool { return !client.channel.LastSeen().IsZero() }, time.Second, time.Millisecond*10)
+	})
+}
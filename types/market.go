@@ -0,0 +1,43 @@
+package types
+
+import (
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// MaxPieceChunkSize the max bytes of a piece read by one ReadPiece request, the larger ranges are read in chunks
+const MaxPieceChunkSize = 16 << 20
+
+// PieceRangeRequest is the payload of IsUnsealed and ReadPiece, the range is the unpadded bytes of sector Sid
+type PieceRangeRequest struct {
+	Miner    address.Address
+	PieceCid cid.Cid
+	Sid      abi.SectorNumber
+	Offset   types.UnpaddedByteIndex
+	Size     abi.UnpaddedPieceSize
+}
+
+// LocatePieceRequest is the payload of LocatePiece
+type LocatePieceRequest struct {
+	Miner    address.Address
+	PieceCid cid.Cid
+}
+
+// PieceLocation is where a piece is stored in a sector
+type PieceLocation struct {
+	Sector   abi.SectorNumber
+	Offset   types.UnpaddedByteIndex
+	Size     abi.UnpaddedPieceSize
+	Unsealed bool
+}
+
+// PieceChunk is a chunk of the piece range streamed by ReadPiece, the stream ends after a chunk with Err
+type PieceChunk struct {
+	Offset types.UnpaddedByteIndex
+	Data   []byte
+	Err    string
+}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/go-jsonrpc"
+)
+
+// DialGateways connects to all the gateway urls by dial, the returned closer closes all of them
+func DialGateways[T any](ctx context.Context, urls []string, token string, dial func(ctx context.Context, url, token string) (T, jsonrpc.ClientCloser, error)) ([]T, jsonrpc.ClientCloser, error) {
+	clients := make([]T, 0, len(urls))
+	closers := make([]jsonrpc.ClientCloser, 0, len(urls))
+	closeAll := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+	for _, url := range urls {
+		client, closer, err := dial(ctx, url, token)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connect to gateway %s: %w", url, err)
+		}
+		clients = append(clients, client)
+		closers = append(closers, closer)
+	}
+	return clients, closeAll, nil
+}
+
+// GatewayClient is the event client connected with one gateway, eg. the proof, market and wallet event clients
+type GatewayClient interface {
+	WaitReady(ctx context.Context)
+	SetWorkerPool(pool *WorkerPool)
+	SetReconnectConfig(cfg ReconnectConfig)
+}
+
+// MultiClient registers to several gateways simultaneously by one client for each, so that requests can still be
+// served through the others when one of the gateways is down
+type MultiClient[C GatewayClient] struct {
+	clients []C
+	listen  func(client C, ctx context.Context) error
+}
+
+// NewMultiClient shares a default worker pool among clients, listen keeps a client listening until it gives up
+func NewMultiClient[C GatewayClient](clients []C, listen func(client C, ctx context.Context) error) *MultiClient[C] {
+	m := &MultiClient[C]{clients: clients, listen: listen}
+	m.SetWorkerPoolConfig(DefaultWorkerPoolConfig)
+	return m
+}
+
+// Clients returns the clients of each gateway in the order of gateways
+func (m *MultiClient[C]) Clients() []C {
+	return m.clients
+}
+
+// ForEach calls f with the clients of all gateways, it only fails when f fails for all of them
+func (m *MultiClient[C]) ForEach(f func(client C) error) error {
+	var errs []string
+	for i, client := range m.clients {
+		if err := f(client); err != nil {
+			errs = append(errs, fmt.Sprintf("gateway %d: %s", i, err))
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(m.clients) {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	for _, err := range errs {
+		log.Warnf("partially failed: %s", err)
+	}
+	return nil
+}
+
+// WaitReady returns once connected with any of the gateways
+func (m *MultiClient[C]) WaitReady(ctx context.Context) {
+	waits := make([]func(context.Context), 0, len(m.clients))
+	for _, client := range m.clients {
+		waits = append(waits, client.WaitReady)
+	}
+	WaitAnyReady(ctx, waits...)
+}
+
+// SetWorkerPoolConfig replaces the worker pool shared by the connections with all gateways, must be called before listening
+func (m *MultiClient[C]) SetWorkerPoolConfig(cfg WorkerPoolConfig) {
+	pool := NewWorkerPool(cfg)
+	for _, client := range m.clients {
+		client.SetWorkerPool(pool)
+	}
+}
+
+// SetReconnectConfig applies to the connections with all gateways, must be called before listening
+func (m *MultiClient[C]) SetReconnectConfig(cfg ReconnectConfig) {
+	for _, client := range m.clients {
+		client.SetReconnectConfig(cfg)
+	}
+}
+
+// Listen returns once gave up all the gateways
+func (m *MultiClient[C]) Listen(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]string, len(m.clients))
+	for i, client := range m.clients {
+		wg.Add(1)
+		go func(i int, client C) {
+			defer wg.Done()
+			if err := m.listen(client, ctx); err != nil {
+				errs[i] = fmt.Sprintf("gateway %d: %s", i, err)
+			}
+		}(i, client)
+	}
+	wg.Wait()
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
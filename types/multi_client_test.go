@@ -0,0 +1,101 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/stretchr/testify/require"
+)
+
+type mockGatewayClient struct {
+	id      int
+	ready   chan struct{}
+	pool    *WorkerPool
+	reconn  ReconnectConfig
+	failing bool
+}
+
+func (m *mockGatewayClient) WaitReady(ctx context.Context) {
+	select {
+	case <-m.ready:
+	case <-ctx.Done():
+	}
+}
+
+func (m *mockGatewayClient) SetWorkerPool(pool *WorkerPool) {
+	m.pool = pool
+}
+
+func (m *mockGatewayClient) SetReconnectConfig(cfg ReconnectConfig) {
+	m.reconn = cfg
+}
+
+func TestMultiClient(t *testing.T) {
+	ctx := context.Background()
+	var clients []*mockGatewayClient
+	for i := 0; i < 3; i++ {
+		clients = append(clients, &mockGatewayClient{id: i, ready: make(chan struct{}), failing: i > 0})
+	}
+	multi := NewMultiClient(clients, func(client *mockGatewayClient, ctx context.Context) error {
+		return fmt.Errorf("gave up %d", client.id)
+	})
+
+	// share the worker pool
+	require.NotNil(t, clients[0].pool)
+	for _, client := range clients {
+		require.Equal(t, clients[0].pool, client.pool)
+	}
+	multi.SetReconnectConfig(ReconnectConfig{MaxAttempts: 3})
+	for _, client := range clients {
+		require.Equal(t, 3, client.reconn.MaxAttempts)
+	}
+
+	// ready once any connected
+	close(clients[1].ready)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	multi.WaitReady(waitCtx)
+	require.NoError(t, waitCtx.Err())
+
+	// partially failed
+	require.NoError(t, multi.ForEach(func(client *mockGatewayClient) error {
+		if client.failing {
+			return fmt.Errorf("mock error")
+		}
+		return nil
+	}))
+	err := multi.ForEach(func(client *mockGatewayClient) error {
+		return fmt.Errorf("mock error")
+	})
+	require.Contains(t, err.Error(), "gateway 0: mock error; gateway 1: mock error; gateway 2: mock error")
+
+	err = multi.Listen(ctx)
+	require.Contains(t, err.Error(), "gateway 0: gave up 0; gateway 1: gave up 1; gateway 2: gave up 2")
+}
+
+func TestDialGateways(t *testing.T) {
+	ctx := context.Background()
+	closed := map[string]bool{}
+	dial := func(ctx context.Context, url, token string) (string, jsonrpc.ClientCloser, error) {
+		if url == "bad" {
+			return "", nil, fmt.Errorf("connection refused")
+		}
+		return url + token, func() { closed[url] = true }, nil
+	}
+
+	clients, closer, err := DialGateways(ctx, []string{"a", "b"}, "token", dial)
+	require.NoError(t, err)
+	require.Equal(t, []string{"atoken", "btoken"}, clients)
+	closer()
+	require.Equal(t, map[string]bool{"a": true, "b": true}, closed)
+
+	// close the connected ones if any failed
+	closed = map[string]bool{}
+	_, _, err = DialGateways(ctx, []string{"a", "bad", "b"}, "token", dial)
+	require.Contains(t, err.Error(), "connect to gateway bad")
+	require.Equal(t, map[string]bool{"a": true}, closed)
+}
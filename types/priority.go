@@ -0,0 +1,182 @@
+package types
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+)
+
+// Priority decides the order of the requests waiting to be sent to the same channel, the higher the earlier
+type Priority int
+
+const (
+	PriorityUnseal Priority = iota
+	PriorityDefault
+	PriorityMessageSign
+	PriorityWinningPoSt
+	PriorityWindowPoSt
+	// PriorityControl is for the events controlling the connection, eg. InitConnect and Reconnect
+	PriorityControl
+)
+
+// maxAgedPriority is the highest priority a request can be promoted to by aging
+const maxAgedPriority = PriorityControl - 1
+
+// DefaultPriorityAging a waiting request is promoted one priority level every DefaultPriorityAging,
+// so that the low priority requests are not starved by the flood of high priority requests
+var DefaultPriorityAging = time.Second * 10
+
+type queuedRequest struct {
+	request  *types.RequestEvent
+	priority Priority
+	enqueued time.Time
+}
+
+// requestQueue is a bounded priority queue of the requests waiting to be sent to a channel
+type requestQueue struct {
+	lk    sync.Mutex
+	items []*queuedRequest
+	aging time.Duration
+
+	space    chan struct{}
+	notEmpty chan struct{}
+}
+
+func newRequestQueue(size int, aging time.Duration) *requestQueue {
+	if size <= 0 {
+		size = 1
+	}
+	return &requestQueue{
+		aging:    aging,
+		space:    make(chan struct{}, size),
+		notEmpty: make(chan struct{}, 1),
+	}
+}
+
+// push waits at most wait for free space of the queue, returns ErrQueueFull if still full
+func (q *requestQueue) push(ctx, chCtx context.Context, request *types.RequestEvent, priority Priority, wait time.Duration) error {
+	if chCtx.Err() != nil {
+		return ErrCloseChannel
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if q.tryPush(request, priority) {
+		return nil
+	}
+	if wait <= 0 {
+		return ErrQueueFull
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case q.space <- struct{}{}:
+	case <-timer.C:
+		return ErrQueueFull
+	case <-chCtx.Done():
+		return ErrCloseChannel
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	q.add(request, priority)
+	return nil
+}
+
+func (q *requestQueue) full() bool {
+	return len(q.space) == cap(q.space)
+}
+
+// tryPush returns false if the queue is full
+func (q *requestQueue) tryPush(request *types.RequestEvent, priority Priority) bool {
+	select {
+	case q.space <- struct{}{}:
+	default:
+		return false
+	}
+	q.add(request, priority)
+	return true
+}
+
+func (q *requestQueue) add(request *types.RequestEvent, priority Priority) {
+	q.lk.Lock()
+	q.items = append(q.items, &queuedRequest{request: request, priority: priority, enqueued: time.Now()})
+	q.lk.Unlock()
+	select {
+	case q.notEmpty <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes the request with the highest effective priority, the earlier one wins on ties
+func (q *requestQueue) pop() *types.RequestEvent {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	now := time.Now()
+	best, bestPriority := 0, q.effectivePriority(q.items[0], now)
+	for i := 1; i < len(q.items); i++ {
+		if p := q.effectivePriority(q.items[i], now); p > bestPriority {
+			best, bestPriority = i, p
+		}
+	}
+	item := q.items[best]
+	q.items = append(q.items[:best], q.items[best+1:]...)
+	<-q.space
+	return item.request
+}
+
+// remove drops the request not sent yet, returns false if it has been taken by pump
+func (q *requestQueue) remove(id sharedTypes.UUID) bool {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	for i, item := range q.items {
+		if item.request.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			<-q.space
+			return true
+		}
+	}
+	return false
+}
+
+// effectivePriority promotes the waiting request by aging, but never to PriorityControl, so that the control events,
+// eg. Ping, are not starved by the requests queued for long
+func (q *requestQueue) effectivePriority(item *queuedRequest, now time.Time) Priority {
+	if q.aging <= 0 || item.priority >= maxAgedPriority {
+		return item.priority
+	}
+	return min(item.priority+Priority(now.Sub(item.enqueued)/q.aging), maxAgedPriority)
+}
+
+func (q *requestQueue) len() int {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	return len(q.items)
+}
+
+// pump delivers the requests to out in priority order until ctx done
+func (q *requestQueue) pump(ctx context.Context, out chan<- *types.RequestEvent) {
+	for {
+		select {
+		case <-q.notEmpty:
+		case <-ctx.Done():
+			return
+		}
+		for request := q.pop(); request != nil; request = q.pop() {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case out <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
+)
+
+func TestRequestQueue(t *testing.T) {
+	ctx := context.Background()
+	newRequest := func(method string) *types.RequestEvent {
+		return &types.RequestEvent{Method: method}
+	}
+
+	t.Run("priority order", func(t *testing.T) {
+		q := newRequestQueue(10, 0)
+		require.True(t, q.tryPush(newRequest("unseal"), PriorityUnseal))
+		require.True(t, q.tryPush(newRequest("sign1"), PriorityMessageSign))
+		require.True(t, q.tryPush(newRequest("list"), PriorityDefault))
+		require.True(t, q.tryPush(newRequest("proof"), PriorityWindowPoSt))
+		require.True(t, q.tryPush(newRequest("sign2"), PriorityMessageSign))
+
+		var methods []string
+		for req := q.pop(); req != nil; req = q.pop() {
+			methods = append(methods, req.Method)
+		}
+		require.Equal(t, []string{"proof", "sign1", "sign2", "list", "unseal"}, methods)
+	})
+
+	t.Run("aging", func(t *testing.T) {
+		q := newRequestQueue(10, time.Millisecond*50)
+		require.True(t, q.tryPush(newRequest("unseal"), PriorityUnseal))
+		time.Sleep(time.Millisecond * 120)
+		require.True(t, q.tryPush(newRequest("sign"), PriorityDefault))
+		require.Equal(t, "unseal", q.pop().Method)
+	})
+
+	t.Run("aging below control", func(t *testing.T) {
+		q := newRequestQueue(10, time.Millisecond*10)
+		require.True(t, q.tryPush(newRequest("unseal"), PriorityUnseal))
+		time.Sleep(time.Millisecond * 200)
+		require.Equal(t, maxAgedPriority, q.effectivePriority(q.items[0], time.Now()))
+		require.True(t, q.tryPush(newRequest("ping"), PriorityControl))
+		require.Equal(t, "ping", q.pop().Method)
+		require.Equal(t, "unseal", q.pop().Method)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		q := newRequestQueue(1, 0)
+		require.True(t, q.tryPush(newRequest("a"), PriorityDefault))
+		require.False(t, q.tryPush(newRequest("b"), PriorityControl))
+
+		pushCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+		require.ErrorIs(t, q.push(pushCtx, ctx, newRequest("b"), PriorityDefault, time.Minute), context.DeadlineExceeded)
+
+		chCtx, chCancel := context.WithCancel(ctx)
+		chCancel()
+		require.ErrorIs(t, q.push(ctx, chCtx, newRequest("b"), PriorityDefault, time.Minute), ErrCloseChannel)
+
+		// no wait or wait timeout when the queue is full
+		require.ErrorIs(t, q.push(ctx, ctx, newRequest("b"), PriorityDefault, 0), ErrQueueFull)
+		start := time.Now()
+		require.ErrorIs(t, q.push(ctx, ctx, newRequest("b"), PriorityDefault, time.Millisecond*50), ErrQueueFull)
+		require.GreaterOrEqual(t, time.Since(start), time.Millisecond*50)
+
+		require.Equal(t, "a", q.pop().Method)
+		require.NoError(t, q.push(ctx, ctx, newRequest("b"), PriorityDefault, 0))
+		require.Equal(t, 1, q.len())
+
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			q.pop()
+		}()
+		require.NoError(t, q.push(ctx, ctx, newRequest("c"), PriorityDefault, time.Second))
+	})
+
+	t.Run("deliver in priority order", func(t *testing.T) {
+		out := make(chan *types.RequestEvent)
+		channel := NewChannelInfo(ctx, "127.1.1.1", out, 10)
+		require.True(t, channel.Enqueue(newRequest("unseal"), PriorityUnseal))
+		// the first request may be taken by pump before the others enqueued
+		require.Eventually(t, func() bool { return channel.queue.len() == 0 }, time.Second, time.Millisecond*10)
+		require.True(t, channel.Enqueue(newRequest("list"), PriorityDefault))
+		require.True(t, channel.Enqueue(newRequest("sign"), PriorityWinningPoSt))
+		require.True(t, channel.Enqueue(newRequest("reconnect"), PriorityControl))
+
+		var methods []string
+		for i := 0; i < 4; i++ {
+			methods = append(methods, (<-out).Method)
+		}
+		require.Equal(t, []string{"unseal", "reconnect", "sign", "list"}, methods)
+
+		channel.Shutdown()
+		_, ok := <-out
+		require.False(t, ok)
+		require.False(t, channel.Enqueue(newRequest("list"), PriorityDefault))
+	})
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MethodProtocol is sent to clients after connected, clients should respond their ClientProtocol
+const MethodProtocol = "Protocol"
+
+// ProtocolVersion is the version of the event protocol, increased once the control events change
+const ProtocolVersion = 1
+
+// ProtocolNegotiateTimeout how long to wait for the protocol of a client, the clients not responding in time
+// are treated as legacy clients, which are sent only the legacy methods of the service
+var ProtocolNegotiateTimeout = time.Second * 10
+
+// ClientProtocol is advertised by the clients, so that the gateway only routes the supported methods to them
+type ClientProtocol struct {
+	Version int
+	// Methods are the request methods handled by the client, including the control methods, eg. Ping
+	Methods []string
+}
+
+// Protocol returns the protocol advertised by the client, nil for the legacy clients or not negotiated yet
+func (c *ChannelInfo) Protocol() *ClientProtocol {
+	return c.protocol.Load()
+}
+
+// restrictLegacy limits the methods routed to the channel to legacyMethods until the client advertises its protocol,
+// the channel is negotiated later, must be called before the channel is visible to the requests
+func (c *ChannelInfo) restrictLegacy(legacyMethods []string) {
+	c.restricted = true
+	c.legacyMethods = legacyMethods
+	c.negotiated = make(chan struct{})
+}
+
+// Supports reports whether the method can be routed to the channel, the channels not restricted by
+// the services, eg. created by NewChannelInfo directly, support all the methods
+func (c *ChannelInfo) Supports(method string) bool {
+	if protocol := c.Protocol(); protocol != nil {
+		return slices.Contains(protocol.Methods, method)
+	}
+	return !c.restricted || slices.Contains(c.legacyMethods, method)
+}
+
+// waitNegotiated returns once the negotiation finished or timed out, or the channel closed
+func (c *ChannelInfo) waitNegotiated(ctx context.Context) {
+	if c.negotiated == nil {
+		return
+	}
+	select {
+	case <-c.negotiated:
+	case <-c.Ctx.Done():
+	case <-ctx.Done():
+	}
+}
+
+// supportedChannels returns the channels supporting method, waits for the negotiation of the channels
+// which may support method after negotiated
+func supportedChannels(ctx context.Context, channels []*ChannelInfo, method string) []*ChannelInfo {
+	supported := make([]*ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		if !channel.Supports(method) {
+			channel.waitNegotiated(ctx)
+		}
+		if channel.Supports(method) {
+			supported = append(supported, channel)
+		}
+	}
+	return supported
+}
+
+// negotiate asks the client of channel for its protocol, the legacy clients respond unsupported method or nothing
+func (e *BaseEventStream) negotiate(channel *ChannelInfo) {
+	if channel.negotiated != nil {
+		defer close(channel.negotiated)
+	}
+	ctx, cancel := context.WithTimeout(channel.Ctx, ProtocolNegotiateTimeout)
+	defer cancel()
+
+	resp, err := e.sendOnce(ctx, channel, MethodProtocol, nil, PriorityControl)
+	if err == nil && len(resp.Error) > 0 {
+		err = fmt.Errorf("client responds error: %s", resp.Error)
+	}
+	var protocol ClientProtocol
+	if err == nil {
+		err = json.Unmarshal(resp.Payload, &protocol)
+	}
+	if err != nil {
+		log.Infof("channel %s(%s) is a legacy client: %v", channel.ChannelId, channel.Ip, err)
+		return
+	}
+	channel.protocol.Store(&protocol)
+	log.Infof("channel %s(%s) supports protocol version %d methods %v", channel.ChannelId, channel.Ip, protocol.Version, protocol.Methods)
+}
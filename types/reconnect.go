@@ -0,0 +1,119 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// ConnectionObserver is notified about the connection state of the event clients,
+// so that the embedding applications can surface the connection health
+type ConnectionObserver interface {
+	// OnConnected is called once the gateway confirms the connection
+	OnConnected(channel sharedTypes.UUID)
+	// OnDisconnected is called when an established connection is closed, err is nil if closed normally
+	OnDisconnected(err error)
+	// OnRetry is called before waiting delay for the attempt-th retry
+	OnRetry(attempt int, delay time.Duration, err error)
+}
+
+type ReconnectConfig struct {
+	Backoff Backoff
+	// MaxAttempts limits the consecutive failed attempts to connect, 0 means retry forever
+	MaxAttempts int
+	// Observer is optional
+	Observer ConnectionObserver
+}
+
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		Backoff: DefaultBackoff,
+	}
+}
+
+// Reconnector keeps an event client connected with gateway
+type Reconnector struct {
+	cfg       ReconnectConfig
+	log       *zap.SugaredLogger
+	readyCh   chan struct{}
+	connected atomic.Bool
+}
+
+func NewReconnector(cfg ReconnectConfig, log *zap.SugaredLogger) *Reconnector {
+	if cfg.Observer == nil {
+		cfg.Observer = nopObserver{}
+	}
+	return &Reconnector{
+		cfg:     cfg,
+		log:     log,
+		readyCh: make(chan struct{}, 1),
+	}
+}
+
+// Connected should be called by the client once receives InitConnect
+func (r *Reconnector) Connected(channel sharedTypes.UUID) {
+	r.connected.Store(true)
+	select {
+	case r.readyCh <- struct{}{}:
+	default:
+	}
+	r.cfg.Observer.OnConnected(channel)
+}
+
+// WaitReady returns once connected
+func (r *Reconnector) WaitReady(ctx context.Context) {
+	select {
+	case <-r.readyCh:
+	case <-ctx.Done():
+	}
+}
+
+// Run calls listenOnce repeatedly until ctx is done or exceeds the max attempts
+func (r *Reconnector) Run(ctx context.Context, listenOnce func(context.Context) error) error {
+	failures := 0
+	for {
+		err := listenOnce(ctx)
+		if r.connected.Swap(false) {
+			failures = 0
+			r.cfg.Observer.OnDisconnected(err)
+		} else {
+			failures++
+		}
+		if err != nil {
+			r.log.Errorf("listen request errored: %s", err)
+		} else {
+			r.log.Warn("listen request quit")
+		}
+
+		if r.cfg.MaxAttempts > 0 && failures >= r.cfg.MaxAttempts {
+			r.log.Errorf("not reconnecting: failed %d times", failures)
+			return fmt.Errorf("give up after %d failed attempts: %w", failures, err)
+		}
+		delay := r.cfg.Backoff.Duration(max(failures-1, 0))
+		r.cfg.Observer.OnRetry(failures+1, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			r.log.Warnf("not reconnecting: context error: %s", ctx.Err())
+			return ctx.Err()
+		}
+
+		r.log.Infof("reconnecting, attempt %d", failures+1)
+		// try clear ready channel
+		select {
+		case <-r.readyCh:
+		default:
+		}
+	}
+}
+
+type nopObserver struct{}
+
+func (nopObserver) OnConnected(sharedTypes.UUID)      {}
+func (nopObserver) OnDisconnected(error)              {}
+func (nopObserver) OnRetry(int, time.Duration, error) {}
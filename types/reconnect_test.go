@@ -0,0 +1,97 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+type recordObserver struct {
+	lk           sync.Mutex
+	connected    []sharedTypes.UUID
+	disconnected []error
+	retries      []int
+}
+
+func (o *recordObserver) OnConnected(channel sharedTypes.UUID) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	o.connected = append(o.connected, channel)
+}
+
+func (o *recordObserver) OnDisconnected(err error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	o.disconnected = append(o.disconnected, err)
+}
+
+func (o *recordObserver) OnRetry(attempt int, _ time.Duration, _ error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	o.retries = append(o.retries, attempt)
+}
+
+func TestReconnector(t *testing.T) {
+	log := logging.Logger("test").With()
+	backoff := Backoff{Min: time.Millisecond, Max: time.Millisecond * 10, Factor: 2}
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		observer := &recordObserver{}
+		r := NewReconnector(ReconnectConfig{Backoff: backoff, MaxAttempts: 3, Observer: observer}, log)
+		errDial := errors.New("dial failed")
+		calls := 0
+		err := r.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errDial
+		})
+		require.ErrorIs(t, err, errDial)
+		require.Equal(t, 3, calls)
+		require.Equal(t, []int{2, 3}, observer.retries)
+		require.Empty(t, observer.connected)
+	})
+
+	t.Run("connected resets attempts", func(t *testing.T) {
+		observer := &recordObserver{}
+		r := NewReconnector(ReconnectConfig{Backoff: backoff, MaxAttempts: 2, Observer: observer}, log)
+		channel := sharedTypes.NewUUID()
+		calls := 0
+		err := r.Run(context.Background(), func(ctx context.Context) error {
+			calls++
+			// fail, connect, fail, connect, fail, fail
+			if calls == 2 || calls == 4 {
+				r.Connected(channel)
+				return nil
+			}
+			return errors.New("dial failed")
+		})
+		require.Error(t, err)
+		require.Equal(t, 6, calls)
+		require.Equal(t, []sharedTypes.UUID{channel, channel}, observer.connected)
+		require.Equal(t, []error{nil, nil}, observer.disconnected)
+		require.Equal(t, []int{2, 1, 2, 1, 2}, observer.retries)
+	})
+
+	t.Run("wait ready and stop", func(t *testing.T) {
+		r := NewReconnector(ReconnectConfig{Backoff: backoff}, log)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- r.Run(ctx, func(ctx context.Context) error {
+				r.Connected(sharedTypes.NewUUID())
+				<-ctx.Done()
+				return nil
+			})
+		}()
+		r.WaitReady(ctx)
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+	})
+}
@@ -0,0 +1,12 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-address"
+)
+
+// TaskRegisterPolicy registers a worker to receive the tasks of miner, Tag tells the workers of a miner apart,
+// eg. the group of sealing workers, the tasks are pushed to one of the workers of the same miner and tag
+type TaskRegisterPolicy struct {
+	Miner address.Address
+	Tag   string
+}
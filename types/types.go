@@ -2,26 +2,124 @@ package types
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/gateway"
 )
 
+// MethodReconnect is sent to clients when the gateway is draining, clients should connect to other gateways
+const MethodReconnect = "Reconnect"
+
+type ReconnectRequest struct {
+	Reason string
+}
+
+// ChannelState extends the ConnectState of a channel with the states only provided by sophon-gateway
+type ChannelState struct {
+	types.ConnectState
+	// Type is wallet, proof or market
+	Type string
+	// Owner is the wallet account or the miner of the channel
+	Owner string
+	// Breaker is the state of the circuit breaker of the channel
+	Breaker string
+	// LastSeen is when the last response of the client was received
+	LastSeen time.Time
+	// RTT is the round trip time of the last heartbeat
+	RTT time.Duration
+	// Version is the protocol version of the client, 0 for the legacy clients
+	Version int
+	// Methods are the request methods supported by the client, empty for the legacy clients
+	Methods []string
+}
+
 type ChannelInfo struct {
-	Ctx        context.Context
-	ChannelId  sharedTypes.UUID
-	Ip         string
+	Ctx       context.Context
+	ChannelId sharedTypes.UUID
+	Ip        string
+	// OutBound is where the requests are delivered to the client in priority order, streams must not write it directly
 	OutBound   chan *types.RequestEvent
 	CreateTime time.Time
+	Capacity   ChannelCapacity
+
+	cancel   context.CancelFunc
+	inflight atomic.Int64
+	queue    *requestQueue
+	pumpDone chan struct{}
+	breaker  circuitBreaker
+	// lastSeen is the unix nano of the last response
+	lastSeen atomic.Int64
+	rtt      atomic.Int64
+	protocol atomic.Pointer[ClientProtocol]
+	// restricted channels are routed only legacyMethods before negotiated, negotiated is closed once negotiation finished
+	restricted    bool
+	legacyMethods []string
+	negotiated    chan struct{}
 }
 
-func NewChannelInfo(ctx context.Context, ip string, sendEvents chan *types.RequestEvent) *ChannelInfo {
-	return &ChannelInfo{
+// NewChannelInfo creates a channel holding at most queueSize requests waiting to be delivered to sendEvents
+func NewChannelInfo(ctx context.Context, ip string, sendEvents chan *types.RequestEvent, queueSize int) *ChannelInfo {
+	ctx, cancel := context.WithCancel(ctx)
+	channel := &ChannelInfo{
 		Ctx:        ctx,
 		ChannelId:  sharedTypes.NewUUID(),
 		OutBound:   sendEvents,
 		Ip:         ip,
 		CreateTime: time.Now(),
+		cancel:     cancel,
+		queue:      newRequestQueue(queueSize, DefaultPriorityAging),
+		pumpDone:   make(chan struct{}),
+	}
+	go func() {
+		defer close(channel.pumpDone)
+		channel.queue.pump(ctx, sendEvents)
+	}()
+	return channel
+}
+
+// State returns the state of the channel of kind owned by the wallet account or miner
+func (c *ChannelInfo) State(kind, owner string, addrs ...address.Address) *ChannelState {
+	state := &ChannelState{
+		ConnectState: types.ConnectState{
+			Addrs:        addrs,
+			ChannelID:    c.ChannelId,
+			IP:           c.Ip,
+			RequestCount: c.Inflight(),
+			CreateTime:   c.CreateTime,
+		},
+		Type:     kind,
+		Owner:    owner,
+		Breaker:  c.BreakerState().String(),
+		LastSeen: c.LastSeen(),
+		RTT:      c.RTT(),
 	}
+	if protocol := c.Protocol(); protocol != nil {
+		state.Version = protocol.Version
+		state.Methods = protocol.Methods
+	}
+	return state
+}
+
+// Enqueue adds a request without waiting, returns false if the queue is full
+func (c *ChannelInfo) Enqueue(request *types.RequestEvent, priority Priority) bool {
+	if c.Ctx.Err() != nil {
+		return false
+	}
+	return c.queue.tryPush(request, priority)
+}
+
+// Close cancels the context of the channel, the stream of the channel will be closed and the client has to connect again
+func (c *ChannelInfo) Close() {
+	c.cancel()
+}
+
+// Shutdown closes the channel and then OutBound once no more requests will be delivered,
+// should be called only by the stream owning OutBound
+func (c *ChannelInfo) Shutdown() {
+	c.cancel()
+	<-c.pumpDone
+	close(c.OutBound)
 }
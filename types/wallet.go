@@ -0,0 +1,60 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// MethodWalletSignBatch signs several requests in one event, only sent to the wallet clients advertising it in their protocol
+const MethodWalletSignBatch = "WalletSignBatch"
+
+// MaxSignBatchSize the max sign requests sent to a wallet client in one WalletSignBatch event
+const MaxSignBatchSize = 50
+
+// signRejectedPrefix marks the rejection in the error responded by wallet clients, followed by the json of SignRejectedError
+const signRejectedPrefix = "sign rejected: "
+
+// SignRejectedError is responded by the wallet clients refusing to sign by their sign policy
+type SignRejectedError struct {
+	Signer address.Address
+	Type   types.MsgType
+	Reason string
+}
+
+func (e *SignRejectedError) Error() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%ssigner %s type %s: %s", signRejectedPrefix, e.Signer, e.Type, e.Reason)
+	}
+	return signRejectedPrefix + string(data)
+}
+
+// ParseSignRejected finds the rejection in the error returned by the wallet clients
+func ParseSignRejected(err error) (*SignRejectedError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	msg := err.Error()
+	idx := strings.Index(msg, signRejectedPrefix)
+	if idx < 0 {
+		return nil, false
+	}
+	rejected := &SignRejectedError{}
+	if err := json.NewDecoder(strings.NewReader(msg[idx+len(signRejectedPrefix):])).Decode(rejected); err != nil {
+		return nil, false
+	}
+	return rejected, true
+}
+
+// SignerSignature is the signature of a signer returned by WalletSignMultisig, Err is set if the signer failed
+type SignerSignature struct {
+	Signer    address.Address
+	Signature *crypto.Signature
+	Err       string
+}
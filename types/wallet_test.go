@@ -0,0 +1,32 @@
+// stm: #unit
+package types
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+func TestParseSignRejected(t *testing.T) {
+	signer := address.NewForTestGetter()()
+	rejected := &SignRejectedError{Signer: signer, Type: types.MTChainMsg, Reason: "not approved"}
+
+	parsed, ok := ParseSignRejected(fmt.Errorf("%s", rejected.Error()))
+	require.True(t, ok)
+	require.Equal(t, rejected, parsed)
+
+	// wrapped by the fan-out of requests
+	parsed, ok = ParseSignRejected(fmt.Errorf("all request failed: WalletSign %s", rejected.Error()))
+	require.True(t, ok)
+	require.Equal(t, rejected, parsed)
+
+	_, ok = ParseSignRejected(fmt.Errorf("mock error"))
+	require.False(t, ok)
+	_, ok = ParseSignRejected(nil)
+	require.False(t, ok)
+}
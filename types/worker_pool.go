@@ -0,0 +1,69 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrOverloaded = errors.New("client overloaded")
+
+type WorkerPoolConfig struct {
+	// MaxConcurrency is the number of requests processed at the same time
+	MaxConcurrency int
+	// MaxQueue is the number of requests waiting for a free worker, more requests will be rejected
+	MaxQueue int
+}
+
+var DefaultWorkerPoolConfig = WorkerPoolConfig{
+	MaxConcurrency: 8,
+	MaxQueue:       64,
+}
+
+// WorkerPool limits the concurrency of the requests processed by the event clients,
+// so that a long request does not block the others on the same channel
+type WorkerPool struct {
+	cfg     WorkerPoolConfig
+	admit   chan struct{}
+	running chan struct{}
+}
+
+func NewWorkerPool(cfg WorkerPoolConfig) *WorkerPool {
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = 1
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	return &WorkerPool{
+		cfg:     cfg,
+		admit:   make(chan struct{}, cfg.MaxConcurrency+cfg.MaxQueue),
+		running: make(chan struct{}, cfg.MaxConcurrency),
+	}
+}
+
+// Submit runs job asynchronously, returns ErrOverloaded if both workers and queue are full.
+// job is dropped if ctx is done before a worker is free
+func (p *WorkerPool) Submit(ctx context.Context, job func(context.Context)) error {
+	select {
+	case p.admit <- struct{}{}:
+	default:
+		return fmt.Errorf("%w: %d requests running and %d queued", ErrOverloaded, p.cfg.MaxConcurrency, p.cfg.MaxQueue)
+	}
+	go func() {
+		defer func() { <-p.admit }()
+		select {
+		case p.running <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-p.running }()
+		job(ctx)
+	}()
+	return nil
+}
+
+// Pending returns the number of requests running or queued
+func (p *WorkerPool) Pending() int {
+	return len(p.admit)
+}
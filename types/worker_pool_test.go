@@ -0,0 +1,66 @@
+// stm: #unit
+package types
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Run("limit concurrency and queue", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewWorkerPool(WorkerPoolConfig{MaxConcurrency: 2, MaxQueue: 1})
+		release := make(chan struct{})
+		var running, maxRunning atomic.Int32
+		done := make(chan struct{}, 3)
+		job := func(ctx context.Context) {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			done <- struct{}{}
+		}
+		for i := 0; i < 3; i++ {
+			require.NoError(t, pool.Submit(ctx, job))
+		}
+		require.ErrorIs(t, pool.Submit(ctx, job), ErrOverloaded)
+		require.Equal(t, 3, pool.Pending())
+		require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, time.Millisecond*10)
+
+		close(release)
+		for i := 0; i < 3; i++ {
+			<-done
+		}
+		require.Equal(t, int32(2), maxRunning.Load())
+		require.Eventually(t, func() bool { return pool.Pending() == 0 }, time.Second, time.Millisecond*10)
+		require.NoError(t, pool.Submit(ctx, func(ctx context.Context) {}))
+	})
+
+	t.Run("drop queued job when context done", func(t *testing.T) {
+		pool := NewWorkerPool(WorkerPoolConfig{MaxConcurrency: 1, MaxQueue: 1})
+		release := make(chan struct{})
+		defer close(release)
+		started := make(chan struct{})
+		require.NoError(t, pool.Submit(context.Background(), func(ctx context.Context) {
+			close(started)
+			<-release
+		}))
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var called atomic.Bool
+		require.NoError(t, pool.Submit(ctx, func(ctx context.Context) { called.Store(true) }))
+		cancel()
+		require.Eventually(t, func() bool { return pool.Pending() == 1 }, time.Second, time.Millisecond*10)
+		require.False(t, called.Load())
+	})
+}
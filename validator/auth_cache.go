@@ -0,0 +1,246 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/ipfs-force-community/sophon-auth/auth"
+	"github.com/ipfs-force-community/sophon-auth/jwtclient"
+
+	"github.com/ipfs-force-community/sophon-gateway/config"
+	"github.com/ipfs-force-community/sophon-gateway/metrics"
+)
+
+var log = logging.Logger("validator")
+
+const (
+	cacheResultHit      = "hit"
+	cacheResultMiss     = "miss"
+	cacheResultStale    = "stale"
+	cacheResultRefresh  = "refresh"
+	cacheResultNegative = "negative"
+	cacheResultError    = "error"
+)
+
+// CachedAuthClient caches the result of the sophon-auth calls made on every connection, a cached result will be refreshed
+// in background before expired, and it will be used for a while after expired if sophon-auth is unreachable.
+type CachedAuthClient struct {
+	jwtclient.IAuthClient
+
+	verifyCache *resultCache[*auth.VerifyResponse]
+	minerCache  *resultCache[bool]
+	usersCache  *resultCache[struct{}]
+}
+
+var _ jwtclient.IAuthClient = (*CachedAuthClient)(nil)
+
+func NewCachedAuthClient(ctx context.Context, authClient jwtclient.IAuthClient, cfg *config.AuthCacheConfig) *CachedAuthClient {
+	c := &CachedAuthClient{
+		IAuthClient: authClient,
+		verifyCache: newResultCache[*auth.VerifyResponse]("Verify", cfg, nil),
+		minerCache:  newResultCache("MinerExistInUser", cfg, func(exist bool) bool { return !exist }),
+		usersCache:  newResultCache[struct{}]("VerifyUsers", cfg, nil),
+	}
+	go c.refreshLoop(ctx, cfg.RefreshInterval)
+	return c
+}
+
+func (c *CachedAuthClient) Verify(ctx context.Context, token string) (*auth.VerifyResponse, error) {
+	return c.verifyCache.get(ctx, token, func(ctx context.Context) (*auth.VerifyResponse, error) {
+		return c.IAuthClient.Verify(ctx, token)
+	})
+}
+
+func (c *CachedAuthClient) MinerExistInUser(ctx context.Context, user string, miner address.Address) (bool, error) {
+	return c.minerCache.get(ctx, user+"/"+miner.String(), func(ctx context.Context) (bool, error) {
+		return c.IAuthClient.MinerExistInUser(ctx, user, miner)
+	})
+}
+
+func (c *CachedAuthClient) VerifyUsers(ctx context.Context, names []string) error {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	_, err := c.usersCache.get(ctx, strings.Join(sorted, ","), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.IAuthClient.VerifyUsers(ctx, sorted)
+	})
+	return err
+}
+
+func (c *CachedAuthClient) refreshLoop(ctx context.Context, interval time.Duration) {
+	tm := time.NewTicker(interval)
+	defer tm.Stop()
+	for {
+		select {
+		case <-tm.C:
+			c.verifyCache.refresh(ctx, interval)
+			c.minerCache.refresh(ctx, interval)
+			c.usersCache.refresh(ctx, interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type cacheEntry[T any] struct {
+	val      T
+	err      error
+	fetchAt  time.Time
+	expireAt time.Time
+	usedAt   time.Time
+	negative bool
+	// fetch is kept to refresh this entry in background
+	fetch      func(context.Context) (T, error)
+	refreshing bool
+}
+
+type resultCache[T any] struct {
+	method string
+	cfg    *config.AuthCacheConfig
+	// negative reports whether a successful result should be cached as negative result
+	negative func(T) bool
+
+	lk      sync.Mutex
+	entries map[string]*cacheEntry[T]
+}
+
+func newResultCache[T any](method string, cfg *config.AuthCacheConfig, negative func(T) bool) *resultCache[T] {
+	return &resultCache[T]{
+		method:   method,
+		cfg:      cfg,
+		negative: negative,
+		entries:  make(map[string]*cacheEntry[T]),
+	}
+}
+
+func (c *resultCache[T]) get(ctx context.Context, key string, fetch func(context.Context) (T, error)) (T, error) {
+	now := time.Now()
+	c.lk.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		entry.usedAt = now
+		if now.Before(entry.expireAt) {
+			val, err, negative := entry.val, entry.err, entry.negative
+			c.lk.Unlock()
+			if negative {
+				c.record(ctx, cacheResultNegative)
+			} else {
+				c.record(ctx, cacheResultHit)
+			}
+			return val, err
+		}
+	}
+	c.lk.Unlock()
+
+	val, err := fetch(ctx)
+	if interrupted(ctx, err) {
+		// not a result of sophon-auth, must not be returned to the other callers
+		c.record(ctx, cacheResultError)
+		return val, err
+	}
+	if err != nil && IsUnavailableError(err) {
+		c.lk.Lock()
+		defer c.lk.Unlock()
+		if entry, ok := c.entries[key]; ok && now.Before(entry.expireAt.Add(c.cfg.MaxStale)) {
+			log.Warnf("sophon-auth unavailable, use result of %s cached at %s: %v", c.method, entry.fetchAt, err)
+			c.record(ctx, cacheResultStale)
+			return entry.val, entry.err
+		}
+		c.record(ctx, cacheResultError)
+		return val, err
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.entries[key] = c.newEntry(now, val, err, fetch)
+	c.record(ctx, cacheResultMiss)
+	return val, err
+}
+
+// refresh fetches the entries which are used since last fetch and going to expire within the next period in background,
+// and removes the entries which can not be used even if sophon-auth is unreachable.
+func (c *resultCache[T]) refresh(ctx context.Context, period time.Duration) {
+	now := time.Now()
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for key, entry := range c.entries {
+		if now.After(entry.expireAt.Add(c.cfg.MaxStale)) {
+			delete(c.entries, key)
+			continue
+		}
+		if entry.refreshing || entry.usedAt.Before(entry.fetchAt) || entry.expireAt.Sub(now) > period {
+			continue
+		}
+
+		entry.refreshing = true
+		go func(key string, entry *cacheEntry[T]) {
+			ctx, cancel := context.WithTimeout(ctx, period)
+			defer cancel()
+
+			now := time.Now()
+			val, err := entry.fetch(ctx)
+			c.lk.Lock()
+			defer c.lk.Unlock()
+			entry.refreshing = false
+			if interrupted(ctx, err) || (err != nil && IsUnavailableError(err)) {
+				log.Debugf("refresh result of %s failed: %v", c.method, err)
+				c.record(ctx, cacheResultError)
+				return
+			}
+			if c.entries[key] == entry {
+				newEntry := c.newEntry(now, val, err, entry.fetch)
+				newEntry.usedAt = entry.usedAt
+				c.entries[key] = newEntry
+			}
+			c.record(ctx, cacheResultRefresh)
+		}(key, entry)
+	}
+}
+
+func (c *resultCache[T]) newEntry(now time.Time, val T, err error, fetch func(context.Context) (T, error)) *cacheEntry[T] {
+	negative := err != nil || (c.negative != nil && c.negative(val))
+	ttl := c.cfg.TTL
+	if negative {
+		ttl = c.cfg.NegativeTTL
+	}
+	return &cacheEntry[T]{
+		val:      val,
+		err:      err,
+		fetchAt:  now,
+		expireAt: now.Add(ttl),
+		negative: negative,
+		fetch:    fetch,
+	}
+}
+
+func (c *resultCache[T]) record(ctx context.Context, result string) {
+	ctx, _ = tag.New(ctx, tag.Upsert(metrics.AuthMethodKey, c.method), tag.Upsert(metrics.CacheResultKey, result))
+	metrics.AuthCache.Tick(ctx)
+}
+
+// interrupted reports whether the fetch failed as ctx is canceled, eg. by the caller or shutdown, rather than a response
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled))
+}
+
+// IsUnavailableError reports whether the error is caused by failing to reach sophon-auth in time,
+// rather than a response from sophon-auth. The requests canceled by the callers are not, even wrapped in url.Error.
+func IsUnavailableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
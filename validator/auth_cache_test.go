@@ -0,0 +1,188 @@
+// stm: #unit
+package validator
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/ipfs-force-community/sophon-auth/auth"
+
+	"github.com/ipfs-force-community/sophon-gateway/config"
+	"github.com/ipfs-force-community/sophon-gateway/validator/mocks"
+)
+
+type countingAuthClient struct {
+	*mocks.AuthClient
+	calls       int64
+	unavailable atomic.Bool
+}
+
+func (c *countingAuthClient) MinerExistInUser(ctx context.Context, user string, miner address.Address) (bool, error) {
+	atomic.AddInt64(&c.calls, 1)
+	if ctx.Err() != nil {
+		return false, &url.Error{Op: "Get", URL: "http://127.0.0.1:8989", Err: ctx.Err()}
+	}
+	if c.unavailable.Load() {
+		return false, &url.Error{Op: "Get", URL: "http://127.0.0.1:8989", Err: errors.New("connection refused")}
+	}
+	return c.AuthClient.MinerExistInUser(ctx, user, miner)
+}
+
+func (c *countingAuthClient) VerifyUsers(ctx context.Context, names []string) error {
+	atomic.AddInt64(&c.calls, 1)
+	if ctx.Err() != nil {
+		return &url.Error{Op: "Post", URL: "http://127.0.0.1:8989", Err: ctx.Err()}
+	}
+	if c.unavailable.Load() {
+		return &url.Error{Op: "Post", URL: "http://127.0.0.1:8989", Err: errors.New("connection refused")}
+	}
+	return c.AuthClient.VerifyUsers(ctx, names)
+}
+
+func (c *countingAuthClient) callCount() int64 {
+	return atomic.LoadInt64(&c.calls)
+}
+
+func setupCachedAuthClient(t *testing.T, cfg *config.AuthCacheConfig) (*CachedAuthClient, *countingAuthClient) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	authClient := &countingAuthClient{AuthClient: mocks.NewMockAuthClient()}
+	authClient.AddMockUser(ctx, &auth.OutputUser{
+		Name:   "test_01",
+		State:  1,
+		Miners: []*auth.OutputMiner{{Miner: f01001, User: "test_01"}},
+	})
+	return NewCachedAuthClient(ctx, authClient, cfg), authClient
+}
+
+func TestCachedAuthClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hit and negative cache", func(t *testing.T) {
+		cachedClient, authClient := setupCachedAuthClient(t, &config.AuthCacheConfig{
+			Enable:          true,
+			TTL:             time.Minute,
+			NegativeTTL:     time.Millisecond * 100,
+			MaxStale:        time.Minute,
+			RefreshInterval: time.Minute,
+		})
+
+		for i := 0; i < 3; i++ {
+			ok, err := cachedClient.MinerExistInUser(ctx, "test_01", f01001)
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		require.EqualValues(t, 1, authClient.callCount())
+
+		for i := 0; i < 3; i++ {
+			ok, err := cachedClient.MinerExistInUser(ctx, "test_01", f01002)
+			require.NoError(t, err)
+			require.False(t, ok)
+		}
+		require.EqualValues(t, 2, authClient.callCount())
+
+		for i := 0; i < 3; i++ {
+			require.Error(t, cachedClient.VerifyUsers(ctx, []string{"test_01", "not_exist"}))
+		}
+		require.EqualValues(t, 3, authClient.callCount())
+
+		// negative result expired, fetch again
+		time.Sleep(time.Millisecond * 200)
+		_, err := cachedClient.MinerExistInUser(ctx, "test_01", f01002)
+		require.NoError(t, err)
+		require.EqualValues(t, 4, authClient.callCount())
+	})
+
+	t.Run("not cache canceled fetch", func(t *testing.T) {
+		cachedClient, authClient := setupCachedAuthClient(t, &config.AuthCacheConfig{
+			Enable:          true,
+			TTL:             time.Minute,
+			NegativeTTL:     time.Minute,
+			MaxStale:        time.Minute,
+			RefreshInterval: time.Minute,
+		})
+
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		require.ErrorIs(t, cachedClient.VerifyUsers(canceledCtx, []string{"test_01"}), context.Canceled)
+		_, err := cachedClient.MinerExistInUser(canceledCtx, "test_01", f01001)
+		require.ErrorIs(t, err, context.Canceled)
+		require.EqualValues(t, 2, authClient.callCount())
+
+		// fetched again for the other callers
+		require.NoError(t, cachedClient.VerifyUsers(ctx, []string{"test_01"}))
+		ok, err := cachedClient.MinerExistInUser(ctx, "test_01", f01001)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.EqualValues(t, 4, authClient.callCount())
+	})
+
+	t.Run("stale while sophon-auth unavailable", func(t *testing.T) {
+		cachedClient, authClient := setupCachedAuthClient(t, &config.AuthCacheConfig{
+			Enable:          true,
+			TTL:             time.Millisecond * 100,
+			NegativeTTL:     time.Millisecond * 100,
+			MaxStale:        time.Millisecond * 500,
+			RefreshInterval: time.Minute,
+		})
+
+		require.NoError(t, cachedClient.VerifyUsers(ctx, []string{"test_01"}))
+		authClient.unavailable.Store(true)
+		time.Sleep(time.Millisecond * 200)
+
+		// expired but still can be used
+		require.NoError(t, cachedClient.VerifyUsers(ctx, []string{"test_01"}))
+		require.EqualValues(t, 2, authClient.callCount())
+
+		// not cached before
+		err := cachedClient.VerifyUsers(ctx, []string{"not_exist"})
+		require.Error(t, err)
+		require.True(t, IsUnavailableError(err))
+
+		time.Sleep(time.Millisecond * 500)
+		require.Error(t, cachedClient.VerifyUsers(ctx, []string{"test_01"}))
+	})
+
+	t.Run("refresh in background", func(t *testing.T) {
+		cachedClient, authClient := setupCachedAuthClient(t, &config.AuthCacheConfig{
+			Enable:          true,
+			TTL:             time.Millisecond * 300,
+			NegativeTTL:     time.Millisecond * 300,
+			MaxStale:        time.Minute,
+			RefreshInterval: time.Millisecond * 200,
+		})
+
+		ok, err := cachedClient.MinerExistInUser(ctx, "test_01", f01001)
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = cachedClient.MinerExistInUser(ctx, "test_01", f01001)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.Eventually(t, func() bool {
+			return authClient.callCount() >= 2
+		}, time.Second*2, time.Millisecond*50)
+
+		// an entry not used since last fetch will not be refreshed again
+		calls := authClient.callCount()
+		time.Sleep(time.Millisecond * 600)
+		require.Equal(t, calls, authClient.callCount())
+	})
+}
+
+func TestIsUnavailableError(t *testing.T) {
+	require.True(t, IsUnavailableError(&url.Error{Op: "Get", URL: "http://127.0.0.1", Err: errors.New("connection refused")}))
+	require.True(t, IsUnavailableError(context.DeadlineExceeded))
+	require.False(t, IsUnavailableError(errors.New("not exist")))
+	require.False(t, IsUnavailableError(context.Canceled))
+	require.False(t, IsUnavailableError(&url.Error{Op: "Get", URL: "http://127.0.0.1", Err: context.Canceled}))
+}
@@ -149,6 +149,15 @@ func (m *AuthClient) AddMockUser(ctx context.Context, users ...*auth.OutputUser)
 	}
 }
 
+func (m *AuthClient) RemoveMockUser(ctx context.Context, names ...string) {
+	m.lkUser.Lock()
+	defer m.lkUser.Unlock()
+
+	for _, name := range names {
+		delete(m.users, name)
+	}
+}
+
 func (m *AuthClient) GetUserLimit(username, service, api string) (*ratelimit.Limit, error) {
 	m.lkUser.Lock()
 	defer m.lkUser.Unlock()
@@ -0,0 +1,33 @@
+package validator
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	EvictReasonToken   = "token"
+	EvictReasonBinding = "binding"
+)
+
+const revalidateTimeout = time.Minute
+
+// Revalidate checks whether a long-lived connection is still authorized by the token saved in its context and the
+// bindings checked by validate, it returns the reason and error if the connection should be closed.
+// Failing to reach sophon-auth does not mean the connection is unauthorized, so it will not be reported,
+// neither the checks interrupted as the connection closed.
+func Revalidate(ctx context.Context, tokenValidator ITokenValidator, validate func(context.Context) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+	defer cancel()
+
+	if token, ok := CtxGetToken(ctx); ok && tokenValidator != nil {
+		if err := tokenValidator.Validate(ctx, token); err != nil && !IsUnavailableError(err) && ctx.Err() == nil {
+			return EvictReasonToken, err
+		}
+	}
+
+	if err := validate(ctx); err != nil && !IsUnavailableError(err) && ctx.Err() == nil {
+		return EvictReasonBinding, err
+	}
+	return "", nil
+}
@@ -0,0 +1,54 @@
+// stm: #unit
+package validator
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockTokenValidator struct {
+	err error
+}
+
+func (m *mockTokenValidator) Validate(ctx context.Context, token string) error {
+	return m.err
+}
+
+func TestRevalidate(t *testing.T) {
+	ctx := CtxWithToken(context.Background(), "token")
+	unavailableErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:8989", Err: errors.New("connection refused")}
+	validBinding := func(context.Context) error { return nil }
+
+	reason, err := Revalidate(ctx, &mockTokenValidator{}, validBinding)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	reason, err = Revalidate(ctx, &mockTokenValidator{err: errors.New("token revoked")}, validBinding)
+	require.Error(t, err)
+	require.Equal(t, EvictReasonToken, reason)
+
+	reason, err = Revalidate(ctx, &mockTokenValidator{}, func(context.Context) error { return errors.New("miner not exist") })
+	require.Error(t, err)
+	require.Equal(t, EvictReasonBinding, reason)
+
+	// sophon-auth unreachable, keep the connection
+	reason, err = Revalidate(ctx, &mockTokenValidator{err: unavailableErr}, func(context.Context) error { return unavailableErr })
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	// the connection closed while revalidating
+	closedCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	reason, err = Revalidate(closedCtx, &mockTokenValidator{err: context.Canceled}, func(context.Context) error { return context.Canceled })
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	// no token saved in context
+	reason, err = Revalidate(context.Background(), &mockTokenValidator{err: errors.New("token revoked")}, validBinding)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+}
@@ -0,0 +1,62 @@
+package validator
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs-force-community/sophon-auth/core"
+	"github.com/ipfs-force-community/sophon-auth/jwtclient"
+)
+
+type ctxKey int
+
+const tokenKey ctxKey = iota
+
+func CtxWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+func CtxGetToken(ctx context.Context) (string, bool) {
+	token, exist := ctx.Value(tokenKey).(string)
+	return token, exist
+}
+
+// TokenHandler saves the token of request in context, so that a long-lived connection can be verified again later
+func TokenHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get(core.AuthorizationHeader), "Bearer ")
+		if token == "" {
+			token = r.FormValue("token")
+		}
+		if token != "" {
+			r = r.WithContext(CtxWithToken(r.Context(), token))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type ITokenValidator interface {
+	Validate(ctx context.Context, token string) error
+}
+
+var _ ITokenValidator = (*TokenValidator)(nil)
+
+// TokenValidator verifies token the same way as the auth mux of gateway, local token first and then remote token
+type TokenValidator struct {
+	local, remote jwtclient.IJwtAuthClient
+}
+
+func NewTokenValidator(local, remote jwtclient.IJwtAuthClient) ITokenValidator {
+	return &TokenValidator{local: local, remote: remote}
+}
+
+func (tv *TokenValidator) Validate(ctx context.Context, token string) error {
+	if tv.local != nil {
+		if _, err := tv.local.Verify(ctx, token); err == nil {
+			return nil
+		}
+	}
+	_, err := tv.remote.Verify(ctx, token)
+	return err
+}
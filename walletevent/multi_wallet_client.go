@@ -0,0 +1,64 @@
+package walletevent
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+
+	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// NewWalletRegisterClients connect to all gateway urls, the returned closer closes all of them
+func NewWalletRegisterClients(ctx context.Context, urls []string, token string) ([]v2API.IWalletServiceProvider, jsonrpc.ClientCloser, error) {
+	return types.DialGateways(ctx, urls, token, NewWalletRegisterClient)
+}
+
+// MultiWalletEventClient registers the wallet to several gateways simultaneously, so that requests can still be
+// served through the others when one of the gateways is down
+type MultiWalletEventClient struct {
+	*types.MultiClient[*WalletEventClient]
+}
+
+func NewMultiWalletEventClient(ctx context.Context, process types.IWalletHandler, clients []v2API.IWalletServiceProvider, log *zap.SugaredLogger, getSupportAccounts func() []string) *MultiWalletEventClient {
+	walletClients := make([]*WalletEventClient, 0, len(clients))
+	for i, client := range clients {
+		walletClients = append(walletClients, NewWalletEventClient(ctx, process, client, log.With("gateway", i), getSupportAccounts))
+	}
+	return &MultiWalletEventClient{MultiClient: types.NewMultiClient(walletClients, (*WalletEventClient).ListenWalletRequest)}
+}
+
+// SupportAccount notify all gateways, it only fails when none of the gateways accepts
+func (e *MultiWalletEventClient) SupportAccount(ctx context.Context, supportAccount string) error {
+	return e.ForEach(func(client *WalletEventClient) error {
+		return client.SupportAccount(ctx, supportAccount)
+	})
+}
+
+func (e *MultiWalletEventClient) AddNewAddress(ctx context.Context, newAddrs []address.Address) error {
+	return e.ForEach(func(client *WalletEventClient) error {
+		return client.AddNewAddress(ctx, newAddrs)
+	})
+}
+
+func (e *MultiWalletEventClient) RemoveAddress(ctx context.Context, newAddrs []address.Address) error {
+	return e.ForEach(func(client *WalletEventClient) error {
+		return client.RemoveAddress(ctx, newAddrs)
+	})
+}
+
+// SetSignPolicy applies to the connections with all gateways, must be called before listening
+func (e *MultiWalletEventClient) SetSignPolicy(policy SignPolicy) {
+	for _, client := range e.Clients() {
+		client.SetSignPolicy(policy)
+	}
+}
+
+// ListenWalletRequest returns once gave up all the gateways
+func (e *MultiWalletEventClient) ListenWalletRequest(ctx context.Context) error {
+	return e.Listen(ctx)
+}
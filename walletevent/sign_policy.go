@@ -0,0 +1,51 @@
+package walletevent
+
+import (
+	"context"
+	"slices"
+
+	"github.com/filecoin-project/go-address"
+
+	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	sharedGatewayTypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
+
+	"github.com/ipfs-force-community/sophon-gateway/types"
+)
+
+// SignPolicy decides whether the wallet client signs the requests pushed by gateway, the rejected requests
+// are responded with types.SignRejectedError
+type SignPolicy struct {
+	// AllowedTypes the MsgMeta types allowed to sign, empty means all types,
+	// MTVerifyAddress is always allowed for gateway to verify the addresses on registration
+	AllowedTypes []sharedTypes.MsgType
+	// AllowedSigners the addresses allowed to sign, empty means all addresses, the others are not listed to gateway
+	AllowedSigners []address.Address
+	// Approve is called after the checks above, eg. to ask a human or an automated risk control,
+	// the returned error rejects the request, it is not called for MTVerifyAddress
+	Approve func(ctx context.Context, req *sharedGatewayTypes.WalletSignRequest) error
+}
+
+func (p *SignPolicy) allowSigner(signer address.Address) bool {
+	return len(p.AllowedSigners) == 0 || slices.Contains(p.AllowedSigners, signer)
+}
+
+func (p *SignPolicy) check(ctx context.Context, req *sharedGatewayTypes.WalletSignRequest) error {
+	reject := func(reason string) error {
+		return &types.SignRejectedError{Signer: req.Signer, Type: req.Meta.Type, Reason: reason}
+	}
+	if !p.allowSigner(req.Signer) {
+		return reject("signer not allowed")
+	}
+	if req.Meta.Type == sharedTypes.MTVerifyAddress {
+		return nil
+	}
+	if len(p.AllowedTypes) > 0 && !slices.Contains(p.AllowedTypes, req.Meta.Type) {
+		return reject("type not allowed")
+	}
+	if p.Approve != nil {
+		if err := p.Approve(ctx, req); err != nil {
+			return reject(err.Error())
+		}
+	}
+	return nil
+}
@@ -2,15 +2,14 @@ package walletevent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
 
 	"github.com/filecoin-project/venus/venus-shared/api"
 	v2API "github.com/filecoin-project/venus/venus-shared/api/gateway/v2"
@@ -35,24 +34,33 @@ type WalletEventClient struct {
 	client             v2API.IWalletServiceProvider
 	randomBytes        []byte
 	log                *zap.SugaredLogger
-	channel            sharedTypes.UUID
 	getSupportAccounts func() []string
-	readyCh            chan struct{}
+	policy             *SignPolicy
+	*types.EventClient
 }
 
 func NewWalletEventClient(ctx context.Context, process types.IWalletHandler, client v2API.IWalletServiceProvider, log *zap.SugaredLogger, getSupportAccounts func() []string) *WalletEventClient {
-	return &WalletEventClient{
+	e := &WalletEventClient{
 		processor:          process,
 		client:             client,
 		log:                log,
 		getSupportAccounts: getSupportAccounts,
 		randomBytes:        sharedGatewayTypes.RandomBytes,
-		readyCh:            make(chan struct{}, 1),
+		EventClient:        types.NewEventClient(log, client.ResponseWalletEvent),
 	}
+	e.Handle("WalletList", e.walletList)
+	e.Handle("WalletSign", types.TypedHandler(e.walletSign))
+	e.Handle(types.MethodWalletSignBatch, types.TypedHandler(e.walletSignBatch))
+	return e
+}
+
+// SetSignPolicy checks the sign requests by policy before signing, must be called before listening
+func (e *WalletEventClient) SetSignPolicy(policy SignPolicy) {
+	e.policy = &policy
 }
 
 func (e *WalletEventClient) SupportAccount(ctx context.Context, supportAccount string) error {
-	err := e.client.SupportNewAccount(ctx, e.channel, supportAccount)
+	err := e.client.SupportNewAccount(ctx, e.ChannelID(), supportAccount)
 	if err != nil {
 		return err
 	}
@@ -60,45 +68,22 @@ func (e *WalletEventClient) SupportAccount(ctx context.Context, supportAccount s
 }
 
 func (e *WalletEventClient) AddNewAddress(ctx context.Context, newAddrs []address.Address) error {
-	return e.client.AddNewAddress(ctx, e.channel, newAddrs)
+	return e.client.AddNewAddress(ctx, e.ChannelID(), newAddrs)
 }
 
 func (e *WalletEventClient) RemoveAddress(ctx context.Context, newAddrs []address.Address) error {
-	return e.client.RemoveAddress(ctx, e.channel, newAddrs)
+	return e.client.RemoveAddress(ctx, e.ChannelID(), newAddrs)
 }
 
-func (e *WalletEventClient) ListenWalletRequest(ctx context.Context) {
-	for {
-		if err := e.listenWalletRequestOnce(ctx); err != nil {
-			e.log.Errorf("listen wallet event errored: %s", err)
-		} else {
-			e.log.Warn("listenWalletRequestOnce quit, try again")
-		}
-		select {
-		case <-time.After(time.Second):
-		case <-ctx.Done():
-			e.log.Warnf("not restarting listenWalletRequestOnce: context error: %s", ctx.Err())
-			return
-		}
-		e.log.Info("restarting listenWalletRequestOnce")
-		// try clear ready channel
-		select {
-		case <-e.readyCh:
-		default:
-		}
-	}
+func (e *WalletEventClient) ListenWalletRequest(ctx context.Context) error {
+	return e.Run(ctx, e.listen)
 }
 
-func (e *WalletEventClient) WaitReady(ctx context.Context) {
-	select {
-	case <-e.readyCh:
-	case <-ctx.Done():
-	}
+func (e *WalletEventClient) listenWalletRequestOnce(ctx context.Context) error {
+	return e.ListenOnce(ctx, e.listen)
 }
 
-func (e *WalletEventClient) listenWalletRequestOnce(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+func (e *WalletEventClient) listen(ctx context.Context) (<-chan *sharedGatewayTypes.RequestEvent, error) {
 	accounts := e.getSupportAccounts()
 	policy := &sharedGatewayTypes.WalletRegisterPolicy{
 		SupportAccounts: accounts,
@@ -107,94 +92,53 @@ func (e *WalletEventClient) listenWalletRequestOnce(ctx context.Context) error {
 	e.log.Infow("", "rand sign byte", e.randomBytes, "support accounts", accounts)
 	walletEventCh, err := e.client.ListenWalletEvent(ctx, policy)
 	if err != nil {
-		// Retry is handled by caller
-		return fmt.Errorf("listenWalletRequestOnce listenWalletRequestOnce call failed: %w", err)
+		return nil, fmt.Errorf("listenWalletRequestOnce listenWalletRequestOnce call failed: %w", err)
 	}
-
-	for event := range walletEventCh {
-		switch event.Method {
-		case "InitConnect":
-			req := sharedGatewayTypes.ConnectedCompleted{}
-			err := json.Unmarshal(event.Payload, &req)
-			if err != nil {
-				e.log.Errorf("init connect error %s", err)
-			}
-			e.channel = req.ChannelId
-			e.log.Infof("connect to server success %v", req.ChannelId)
-			e.readyCh <- struct{}{}
-			// do not response
-		case "WalletList":
-			go e.walletList(ctx, event.ID)
-		case "WalletSign":
-			go e.walletSign(ctx, event)
-		default:
-			e.log.Errorf("unexpect proof event type %s", event.Method)
-		}
-	}
-
-	return nil
+	return walletEventCh, nil
 }
 
-func (e *WalletEventClient) walletList(ctx context.Context, id sharedTypes.UUID) {
+func (e *WalletEventClient) walletList(ctx context.Context, _ []byte) (interface{}, error) {
 	addrs, err := e.processor.WalletList(ctx)
-	if err != nil {
-		e.log.Errorf("WalletList error %s", err)
-		e.error(ctx, id, err)
-		return
+	if err != nil || e.policy == nil {
+		return addrs, err
+	}
+	allowed := make([]address.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		if e.policy.allowSigner(addr) {
+			allowed = append(allowed, addr)
+		}
 	}
-	e.value(ctx, id, addrs)
+	return allowed, nil
 }
 
-func (e *WalletEventClient) walletSign(ctx context.Context, event *sharedGatewayTypes.RequestEvent) {
-	e.log.Debug("receive WalletSign event")
-	req := sharedGatewayTypes.WalletSignRequest{}
-	err := json.Unmarshal(event.Payload, &req)
-	if err != nil {
-		e.log.Errorf("unmarshal WalletSignRequest error %s", err)
-		e.error(ctx, event.ID, err)
-		return
-	}
+func (e *WalletEventClient) walletSign(ctx context.Context, req sharedGatewayTypes.WalletSignRequest) (*crypto.Signature, error) {
 	e.log.Debug("start WalletSign")
+	if e.policy != nil {
+		if err := e.policy.check(ctx, &req); err != nil {
+			e.log.Warnf("reject to sign by %s: %s", req.Signer, err)
+			return nil, err
+		}
+	}
 	sig, err := e.processor.WalletSign(ctx, req.Signer, req.ToSign, sharedTypes.MsgMeta{Type: req.Meta.Type, Extra: req.Meta.Extra})
 	if err != nil {
-		e.log.Errorf("WalletSign error %s", err)
-		e.error(ctx, event.ID, err)
-		return
+		return nil, err
 	}
 	e.log.Debug("end WalletSign")
-	e.value(ctx, event.ID, sig)
-	e.log.Debug("end WalletSign response")
+	return sig, nil
 }
 
-func (e *WalletEventClient) value(ctx context.Context, id sharedTypes.UUID, val interface{}) {
-	respBytes, err := json.Marshal(val)
-	if err != nil {
-		e.log.Errorf("marshal address list error %s", err)
-		err = e.client.ResponseWalletEvent(ctx, &sharedGatewayTypes.ResponseEvent{
-			ID:      id,
-			Payload: nil,
-			Error:   err.Error(),
-		})
-		e.log.Errorf("response wallet event error %s", err)
-		return
-	}
-	err = e.client.ResponseWalletEvent(ctx, &sharedGatewayTypes.ResponseEvent{
-		ID:      id,
-		Payload: respBytes,
-		Error:   "",
-	})
-	if err != nil {
-		e.log.Errorf("response error %v", err)
+func (e *WalletEventClient) walletSignBatch(ctx context.Context, reqs []sharedGatewayTypes.WalletSignRequest) ([]types.SignerSignature, error) {
+	if len(reqs) > types.MaxSignBatchSize {
+		return nil, fmt.Errorf("sign batch size %d exceeds the limit %d", len(reqs), types.MaxSignBatchSize)
 	}
-}
-
-func (e *WalletEventClient) error(ctx context.Context, id sharedTypes.UUID, err error) {
-	err = e.client.ResponseWalletEvent(ctx, &sharedGatewayTypes.ResponseEvent{
-		ID:      id,
-		Payload: nil,
-		Error:   err.Error(),
-	})
-	if err != nil {
-		e.log.Errorf("response error %v", err)
+	results := make([]types.SignerSignature, 0, len(reqs))
+	for _, req := range reqs {
+		sig, err := e.walletSign(ctx, req)
+		result := types.SignerSignature{Signer: req.Signer, Signature: sig}
+		if err != nil {
+			result.Err = err.Error()
+		}
+		results = append(results, result)
 	}
+	return results, nil
 }
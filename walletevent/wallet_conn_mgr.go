@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
@@ -16,6 +17,10 @@ type walletChannelInfo struct {
 	addrs map[address.Address]struct{} // signer address
 	// a slice byte provide by wallet, using to verify address is really exist
 	signBytes []byte
+
+	// probeLk serializes the probes of the wallet addresses, probedAt is the time of the last probe
+	probeLk  sync.Mutex
+	probedAt time.Time
 }
 
 func newWalletChannelInfo(channelInfo *types.ChannelInfo, addrs []address.Address, signBytes []byte) *walletChannelInfo {
@@ -37,10 +42,15 @@ type IWalletConnMgr interface {
 	getConn(walletAccount string, channelID sharedTypes.UUID) (*walletChannelInfo, error)
 	removeConn(string, *walletChannelInfo) error
 	addSupportAccount(string, string) error
+	removeSupportAccount(walletAccount string, supportAccount string)
 	getChannels(string, address.Address) ([]*types.ChannelInfo, error)
 	addNewAddress(walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) error
 	removeAddress(walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) error
 	hasWalletChannel(supportAccount string, from address.Address) (bool, error)
+	supportChannels(supportAccounts []string) map[string][]*walletChannelInfo
+	unknownAddresses(walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) []address.Address
+	listConns() map[string][]*walletChannelInfo
+	listChannelStates() []*types.ChannelState
 
 	listWalletInfo(ctx context.Context) ([]*types2.WalletDetail, error)
 	listWalletInfoByWallet(ctx context.Context, wallet string) (*types2.WalletDetail, error)
@@ -118,6 +128,9 @@ func (w *walletConnMgr) removeConn(walletAccount string, info *walletChannelInfo
 	defer w.infoLk.Unlock()
 
 	if walletInfo, ok := w.walletInfos[walletAccount]; ok {
+		if _, ok := walletInfo.connections[info.ChannelId]; !ok {
+			return nil
+		}
 		delete(walletInfo.connections, info.ChannelId)
 		if len(walletInfo.connections) == 0 {
 			delete(w.walletInfos, walletAccount)
@@ -138,6 +151,16 @@ func (w *walletConnMgr) addSupportAccount(walletAccount string, supportAccount s
 	return nil
 }
 
+// removeSupportAccount stops signing for supportAccount by the wallet, the wallet account itself is always supported
+func (w *walletConnMgr) removeSupportAccount(walletAccount string, supportAccount string) {
+	w.infoLk.Lock()
+	defer w.infoLk.Unlock()
+
+	if walletInfo, ok := w.walletInfos[walletAccount]; ok && supportAccount != walletAccount {
+		delete(walletInfo.supportAccounts, supportAccount)
+	}
+}
+
 func (w *walletConnMgr) getChannels(supportAccount string, from address.Address) ([]*types.ChannelInfo, error) {
 	w.infoLk.Lock()
 	defer w.infoLk.Unlock()
@@ -174,6 +197,47 @@ func (w *walletConnMgr) hasWalletChannel(supportAccount string, from address.Add
 	return false, nil
 }
 
+// supportChannels returns the connections of the wallets supporting any of supportAccounts by wallet account
+func (w *walletConnMgr) supportChannels(supportAccounts []string) map[string][]*walletChannelInfo {
+	w.infoLk.Lock()
+	defer w.infoLk.Unlock()
+
+	conns := make(map[string][]*walletChannelInfo)
+	for walletAccount, walletInfo := range w.walletInfos {
+		for _, supportAccount := range supportAccounts {
+			if _, ok := walletInfo.supportAccounts[supportAccount]; ok {
+				for _, conn := range walletInfo.connections {
+					conns[walletAccount] = append(conns[walletAccount], conn)
+				}
+				break
+			}
+		}
+	}
+	return conns
+}
+
+// unknownAddresses returns the addrs not added to the connection yet
+func (w *walletConnMgr) unknownAddresses(walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) []address.Address {
+	w.infoLk.Lock()
+	defer w.infoLk.Unlock()
+
+	walletInfo, ok := w.walletInfos[walletAccount]
+	if !ok {
+		return nil
+	}
+	channel, ok := walletInfo.connections[channelId]
+	if !ok {
+		return nil
+	}
+	var unknown []address.Address
+	for _, addr := range addrs {
+		if _, ok := channel.addrs[addr]; !ok {
+			unknown = append(unknown, addr)
+		}
+	}
+	return unknown
+}
+
 func (w *walletConnMgr) addNewAddress(walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) error {
 	w.infoLk.Lock()
 	defer w.infoLk.Unlock()
@@ -206,6 +270,36 @@ func (w *walletConnMgr) removeAddress(walletAccount string, channelId sharedType
 	return nil
 }
 
+func (w *walletConnMgr) listConns() map[string][]*walletChannelInfo {
+	w.infoLk.Lock()
+	defer w.infoLk.Unlock()
+
+	conns := make(map[string][]*walletChannelInfo, len(w.walletInfos))
+	for walletAccount, walletInfo := range w.walletInfos {
+		for _, conn := range walletInfo.connections {
+			conns[walletAccount] = append(conns[walletAccount], conn)
+		}
+	}
+	return conns
+}
+
+func (w *walletConnMgr) listChannelStates() []*types.ChannelState {
+	w.infoLk.Lock()
+	defer w.infoLk.Unlock()
+
+	var states []*types.ChannelState
+	for walletAccount, walletInfo := range w.walletInfos {
+		for _, conn := range walletInfo.connections {
+			addrs := make([]address.Address, 0, len(conn.addrs))
+			for addr := range conn.addrs {
+				addrs = append(addrs, addr)
+			}
+			states = append(states, conn.State("wallet", walletAccount, addrs...))
+		}
+	}
+	return states
+}
+
 func (w *walletConnMgr) listWalletInfo(ctx context.Context) ([]*types2.WalletDetail, error) {
 	w.infoLk.Lock()
 	defer w.infoLk.Unlock()
@@ -226,7 +320,7 @@ func (w *walletConnMgr) listWalletInfo(ctx context.Context) ([]*types2.WalletDet
 			cstate := types2.ConnectState{
 				Addrs:        addrs,
 				ChannelID:    channelId,
-				RequestCount: len(wallet.OutBound),
+				RequestCount: wallet.Inflight(),
 				IP:           wallet.Ip,
 				CreateTime:   wallet.CreateTime,
 			}
@@ -257,7 +351,7 @@ func (w *walletConnMgr) listWalletInfoByWallet(ctx context.Context, wallet strin
 				Addrs:        addrs,
 				ChannelID:    channelId,
 				IP:           wallet.Ip,
-				RequestCount: len(wallet.OutBound),
+				RequestCount: wallet.Inflight(),
 				CreateTime:   wallet.CreateTime,
 			}
 			walletDetail.ConnectStates = append(walletDetail.ConnectStates, cstate)
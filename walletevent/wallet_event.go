@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opencensus.io/stats"
@@ -30,6 +33,7 @@ import (
 
 	"github.com/ipfs-force-community/sophon-gateway/metrics"
 	"github.com/ipfs-force-community/sophon-gateway/types"
+	"github.com/ipfs-force-community/sophon-gateway/validator"
 )
 
 var log = logging.Logger("event_stream")
@@ -41,16 +45,29 @@ type WalletEventStream struct {
 	cfg           *types.RequestConfig
 	authClient    jwtclient.IAuthClient
 	randBytes     []byte
-	*types.BaseEventStream
+	*types.EventService[string]
 }
 
 func NewWalletEventStream(ctx context.Context, authClient jwtclient.IAuthClient, cfg *types.RequestConfig) *WalletEventStream {
 	walletEventStream := &WalletEventStream{
-		walletConnMgr:   newWalletConnMgr(),
-		BaseEventStream: types.NewBaseEventStream(ctx, cfg),
-		cfg:             cfg,
-		authClient:      authClient,
+		walletConnMgr: newWalletConnMgr(),
+		cfg:           cfg,
+		authClient:    authClient,
 	}
+	walletEventStream.EventService = types.NewEventService(ctx, types.EventServiceConfig[string]{
+		Kind:          "wallet",
+		KeyName:       "wallet",
+		LegacyMethods: []string{"WalletList", "WalletSign"},
+		// Verify account: must exist in venus-auth, the support accounts no longer existing are dropped
+		Validate: func(ctx context.Context, walletAccount string) error {
+			if err := authClient.VerifyUsers(ctx, []string{walletAccount}); err != nil {
+				return fmt.Errorf("verify user %s failed: %w", walletAccount, err)
+			}
+			walletEventStream.verifySupportAccounts(ctx, walletAccount)
+			return nil
+		},
+		OnRemoved: walletEventStream.removeConn,
+	}, cfg)
 	var err error
 	walletEventStream.randBytes, err = ioutil.ReadAll(io.LimitReader(rand.Reader, 32))
 	if err != nil {
@@ -67,89 +84,96 @@ func (w *WalletEventStream) ListenWalletEvent(ctx context.Context, policy *share
 	}
 
 	ip, _ := core.CtxGetTokenLocation(ctx) // todo sure exit?
-	out := make(chan *sharedGatewayTypes.RequestEvent, w.cfg.RequestQueueSize)
-	walletLog := log.With("account", walletAccount).With("ip", ip)
 	ctx, _ = tag.New(ctx, tag.Upsert(metrics.WalletAccountKey, walletAccount), tag.Upsert(metrics.IPKey, ip))
 
-	// Verify account: must exist in venus-auth
-	accounts := policy.SupportAccounts
-	accounts = append(accounts, walletAccount)
-	err := w.authClient.VerifyUsers(ctx, accounts)
-	if err != nil {
-		return nil, fmt.Errorf("verify user %v failed: %w", accounts, err)
+	// Verify support accounts: must exist in venus-auth, the wallet account is verified on registering
+	if len(policy.SupportAccounts) > 0 {
+		if err := w.authClient.VerifyUsers(ctx, policy.SupportAccounts); err != nil {
+			return nil, fmt.Errorf("verify user %v failed: %w", policy.SupportAccounts, err)
+		}
 	}
 
-	go func() {
-		channel := types.NewChannelInfo(ctx, ip, out)
-		defer close(out)
-		addrs, err := w.getValidatedAddress(ctx, channel, policy.SignBytes, walletAccount)
-		if err != nil {
-			walletLog.Errorf("unable to value address %v", err)
-			return
-		}
+	return w.Register(ctx, walletAccount, ip, types.RegisterOptions{
+		Prepare: func(ctx context.Context, channel *types.ChannelInfo) error {
+			return w.addConn(ctx, walletAccount, policy, channel)
+		},
+	})
+}
 
-		walletChannelInfo := newWalletChannelInfo(channel, addrs, policy.SignBytes)
-		err = w.walletConnMgr.addNewConn(walletAccount, policy, walletChannelInfo)
-		if err != nil {
-			walletLog.Errorf("validate address error %v", err)
-			return
-		}
-		walletLog.Infof("add new connections %s for wallet", walletChannelInfo.ChannelId)
+// addConn validates the addresses of the wallet, the channel is visible to signing once added
+func (w *WalletEventStream) addConn(ctx context.Context, walletAccount string, policy *sharedGatewayTypes.WalletRegisterPolicy, channel *types.ChannelInfo) error {
+	addrs, err := w.getValidatedAddress(ctx, channel, policy.SignBytes, walletAccount)
+	if err != nil {
+		return fmt.Errorf("unable to value address %w", err)
+	}
 
-		// register signer address to venus-auth
-		for _, account := range accounts {
-			if err := w.registerSignerAddress(ctx, account, addrs...); err != nil {
-				log.Errorf("register %v for %s failed: %v", addrs, account, err)
-				continue
-			}
-			log.Infof("register %v for %s success", addrs, account)
+	walletChannelInfo := newWalletChannelInfo(channel, addrs, policy.SignBytes)
+	if err := w.walletConnMgr.addNewConn(walletAccount, policy, walletChannelInfo); err != nil {
+		return fmt.Errorf("validate address error %w", err)
+	}
+
+	// register signer address to venus-auth
+	accounts := append([]string{walletAccount}, policy.SupportAccounts...)
+	for _, account := range accounts {
+		if err := w.registerSignerAddress(ctx, account, addrs...); err != nil {
+			log.Errorf("register %v for %s failed: %v", addrs, account, err)
+			continue
 		}
+		log.Infof("register %v for %s success", addrs, account)
+	}
 
-		// todo rescan address to add new address or remove
+	// todo rescan address to add new address or remove
 
-		stats.Record(ctx, metrics.WalletRegister.M(1))
+	stats.Record(ctx, metrics.WalletRegister.M(1))
+	return nil
+}
 
-		connectBytes, err := json.Marshal(sharedGatewayTypes.ConnectedCompleted{
-			ChannelId: walletChannelInfo.ChannelId,
-		})
-		if err != nil {
-			walletLog.Errorf("marshal failed %v", err)
-			return
+// verifySupportAccounts drops the support accounts of the wallet failing to verify, the wallet keeps signing for the others
+func (w *WalletEventStream) verifySupportAccounts(ctx context.Context, walletAccount string) {
+	walletInfo, err := w.walletConnMgr.listWalletInfoByWallet(ctx, walletAccount)
+	if err != nil {
+		return
+	}
+	for _, account := range walletInfo.SupportAccounts {
+		if account == walletAccount {
+			continue
 		}
-
-		out <- &sharedGatewayTypes.RequestEvent{
-			ID:         sharedTypes.NewUUID(),
-			Method:     "InitConnect",
-			CreateTime: time.Now(),
-			Payload:    connectBytes,
-			Result:     nil,
-		} // not response
-
-		<-ctx.Done()
-		stats.Record(ctx, metrics.WalletUnregister.M(1))
-		if err = w.walletConnMgr.removeConn(walletAccount, walletChannelInfo); err != nil {
-			walletLog.Errorf("remove connection %s failed: %v", walletChannelInfo.ChannelId, err)
-		} else { // nolint
-			// The records bound to the system will not have a lot of records, and there will be no additional effects.
-			// There are expenses and other potential risks for each disconnection of betting sales.
-			// Therefore, it is not used first.
-			//// unregister all signer of this account
-			//signers := make([]address.Address, len(walletChannelInfo.addrs))
-			//idx := 0
-			//for addr := range walletChannelInfo.addrs {
-			//	signers[idx] = addr
-			//	idx++
-			//}
-			//
-			//if err := w.unregisterSignerAddress(ctx, walletAccount, signers...); err != nil {
-			//	log.Errorf("unregister %v for %s failed: %w", signers, walletAccount, err)
-			//} else {
-			//	log.Infof("unregister %v for %s success", signers, walletAccount)
-			//}
+		err := w.authClient.VerifyUsers(ctx, []string{account})
+		if err == nil || validator.IsUnavailableError(err) || ctx.Err() != nil {
+			continue
 		}
-		walletLog.Infof("remove connection %s of wallet", walletChannelInfo.ChannelId)
-	}()
-	return out, nil
+		log.Warnf("drop support account %s of wallet %s: %v", account, walletAccount, err)
+		w.walletConnMgr.removeSupportAccount(walletAccount, account)
+	}
+}
+
+func (w *WalletEventStream) removeConn(walletAccount string, channel *types.ChannelInfo) {
+	stats.Record(channel.Ctx, metrics.WalletUnregister.M(1))
+	walletChannelInfo, err := w.walletConnMgr.getConn(walletAccount, channel.ChannelId)
+	if err != nil {
+		log.Errorf("remove connection %s failed: %v", channel.ChannelId, err)
+		return
+	}
+	if err = w.walletConnMgr.removeConn(walletAccount, walletChannelInfo); err != nil {
+		log.Errorf("remove connection %s failed: %v", channel.ChannelId, err)
+	} else { // nolint
+		// The records bound to the system will not have a lot of records, and there will be no additional effects.
+		// There are expenses and other potential risks for each disconnection of betting sales.
+		// Therefore, it is not used first.
+		//// unregister all signer of this account
+		//signers := make([]address.Address, len(walletChannelInfo.addrs))
+		//idx := 0
+		//for addr := range walletChannelInfo.addrs {
+		//	signers[idx] = addr
+		//	idx++
+		//}
+		//
+		//if err := w.unregisterSignerAddress(ctx, walletAccount, signers...); err != nil {
+		//	log.Errorf("unregister %v for %s failed: %w", signers, walletAccount, err)
+		//} else {
+		//	log.Infof("unregister %v for %s success", signers, walletAccount)
+		//}
+	}
 }
 
 func (w *WalletEventStream) ResponseWalletEvent(ctx context.Context, resp *sharedGatewayTypes.ResponseEvent) error {
@@ -207,7 +231,12 @@ func (w *WalletEventStream) AddNewAddress(ctx context.Context, channelId sharedT
 			metrics.WalletAddAddr.M(1))
 	}
 
-	err = w.walletConnMgr.addNewAddress(walletAccount, channelId, addrs)
+	return w.addAddress(ctx, walletAccount, channelId, addrs)
+}
+
+// addAddress adds the verified addrs to the connection and registers them as signers of the support accounts
+func (w *WalletEventStream) addAddress(ctx context.Context, walletAccount string, channelId sharedTypes.UUID, addrs []address.Address) error {
+	err := w.walletConnMgr.addNewAddress(walletAccount, channelId, addrs)
 	if err != nil {
 		log.Errorf("wallet %s add address %v failed %v", walletAccount, addrs, err)
 		return err
@@ -263,6 +292,15 @@ func (w *WalletEventStream) RemoveAddress(ctx context.Context, channelId sharedT
 }
 
 func (w *WalletEventStream) WalletHas(ctx context.Context, addr address.Address, accounts []string) (bool, error) {
+	has, err := w.hasAddress(addr, accounts)
+	if err != nil || has || !w.cfg.WalletProbe {
+		return has, err
+	}
+	w.probe(ctx, accounts)
+	return w.hasAddress(addr, accounts)
+}
+
+func (w *WalletEventStream) hasAddress(addr address.Address, accounts []string) (bool, error) {
 	for _, account := range accounts {
 		bHas, err := w.walletConnMgr.hasWalletChannel(account, addr)
 		if err != nil {
@@ -277,7 +315,65 @@ func (w *WalletEventStream) WalletHas(ctx context.Context, addr address.Address,
 	return false, nil
 }
 
+// probe asks the wallets supporting accounts to list their addresses again, the new addresses are verified
+// and added to the connections
+func (w *WalletEventStream) probe(ctx context.Context, accounts []string) {
+	for walletAccount, conns := range w.walletConnMgr.supportChannels(accounts) {
+		for _, conn := range conns {
+			if err := w.probeConn(ctx, walletAccount, conn); err != nil {
+				log.Warnf("probe wallet %s of channel %s failed: %v", walletAccount, conn.ChannelId, err)
+			}
+		}
+	}
+}
+
+// probeConn is skipped if conn was probed within WalletProbeInterval
+func (w *WalletEventStream) probeConn(ctx context.Context, walletAccount string, conn *walletChannelInfo) error {
+	conn.probeLk.Lock()
+	defer conn.probeLk.Unlock()
+	if time.Since(conn.probedAt) < w.cfg.WalletProbeInterval {
+		return nil
+	}
+	conn.probedAt = time.Now()
+
+	var addrs []address.Address
+	start := time.Now()
+	if err := w.SendRequest(ctx, []*types.ChannelInfo{conn.ChannelInfo}, "WalletList", nil, &addrs); err != nil {
+		return err
+	}
+	stats.Record(ctx, metrics.WalletList.M(metrics.SinceInMilliseconds(start)))
+
+	unknown := w.walletConnMgr.unknownAddresses(walletAccount, conn.ChannelId, addrs)
+	verified := make([]address.Address, 0, len(unknown))
+	for _, addr := range unknown {
+		if err := w.verifyAddress(ctx, addr, conn.ChannelInfo, conn.signBytes, walletAccount); err != nil {
+			log.Warnf("skip the probed address: %v", err)
+			continue
+		}
+		verified = append(verified, addr)
+	}
+	if len(verified) == 0 {
+		return nil
+	}
+	return w.addAddress(ctx, walletAccount, conn.ChannelId, verified)
+}
+
 func (w *WalletEventStream) WalletSign(ctx context.Context, addr address.Address, accounts []string, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error) {
+	channels := w.signerChannels(ctx, addr, accounts)
+
+	start := time.Now()
+	result, err := w.sign(ctx, channels, &sharedGatewayTypes.WalletSignRequest{
+		Signer: addr,
+		ToSign: toSign,
+		Meta:   meta,
+	})
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.WalletAccountKey, fmt.Sprintf("%v", accounts))},
+		metrics.WalletSign.M(metrics.SinceInMilliseconds(start)))
+	return result, err
+}
+
+// signerChannels returns the connections of the wallets holding addr for accounts
+func (w *WalletEventStream) signerChannels(ctx context.Context, addr address.Address, accounts []string) []*types.ChannelInfo {
 	channels := make([]*types.ChannelInfo, 0)
 	for _, account := range accounts {
 		cs, err := w.walletConnMgr.getChannels(account, addr)
@@ -289,35 +385,230 @@ func (w *WalletEventStream) WalletSign(ctx context.Context, addr address.Address
 
 		channels = append(channels, cs...)
 	}
+	if len(channels) == 0 && w.cfg.WalletProbe {
+		w.probe(ctx, accounts)
+		for _, account := range accounts {
+			if cs, err := w.walletConnMgr.getChannels(account, addr); err == nil {
+				channels = append(channels, cs...)
+			}
+		}
+	}
+	return channels
+}
 
-	start := time.Now()
+func (w *WalletEventStream) sign(ctx context.Context, channels []*types.ChannelInfo, req *sharedGatewayTypes.WalletSignRequest) (*crypto.Signature, error) {
 	var result crypto.Signature
-	payload, err := json.Marshal(&sharedGatewayTypes.WalletSignRequest{
-		Signer: addr,
-		ToSign: toSign,
-		Meta:   meta,
-	})
+	payload, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
-	err = w.SendRequest(ctx, channels, "WalletSign", payload, &result)
-	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.WalletAccountKey, fmt.Sprintf("%v", accounts))},
-		metrics.WalletSign.M(metrics.SinceInMilliseconds(start)))
+	err = w.SendRequestWithPriority(ctx, channels, "WalletSign", payload, signPriority(req.Meta), &result)
 	if err != nil {
+		// unwrap the rejection from the errors of fan-out
+		if rejected, ok := types.ParseSignRejected(err); ok {
+			return nil, rejected
+		}
 		return nil, err
 	}
 
 	return &result, nil
 }
 
+// WalletSignBatch signs reqs in batches, the requests of the signers held by the same wallet connections are sent together
+// in one WalletSignBatch event, and signed one by one for the clients not supporting it, the results are in the order of reqs
+func (w *WalletEventStream) WalletSignBatch(ctx context.Context, accounts []string, reqs []*sharedGatewayTypes.WalletSignRequest) ([]types.SignerSignature, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no sign requests")
+	}
+
+	type batch struct {
+		channels []*types.ChannelInfo
+		indexes  []int
+	}
+	results := make([]types.SignerSignature, len(reqs))
+	batches := make(map[string]*batch)
+	var keys []string
+	for i, req := range reqs {
+		results[i].Signer = req.Signer
+		channels := w.signerChannels(ctx, req.Signer, accounts)
+		if len(channels) == 0 {
+			results[i].Err = fmt.Sprintf("no connect found for signer %s", req.Signer)
+			continue
+		}
+		ids := make([]string, 0, len(channels))
+		for _, channel := range channels {
+			ids = append(ids, channel.ChannelId.String())
+		}
+		slices.Sort(ids)
+		key := strings.Join(ids, ",")
+		b, ok := batches[key]
+		if !ok {
+			b = &batch{channels: channels}
+			batches[key] = b
+			keys = append(keys, key)
+		}
+		b.indexes = append(b.indexes, i)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		b := batches[key]
+		for indexes := range slices.Chunk(b.indexes, types.MaxSignBatchSize) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.signBatch(ctx, b.channels, reqs, indexes, results)
+			}()
+		}
+	}
+	wg.Wait()
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(metrics.WalletAccountKey, fmt.Sprintf("%v", accounts))},
+		metrics.WalletSignBatch.M(metrics.SinceInMilliseconds(start)))
+
+	return results, nil
+}
+
+// signBatch fills the results of reqs at indexes, which are signed by the same wallet connections
+func (w *WalletEventStream) signBatch(ctx context.Context, channels []*types.ChannelInfo, reqs []*sharedGatewayTypes.WalletSignRequest, indexes []int, results []types.SignerSignature) {
+	batch := make([]*sharedGatewayTypes.WalletSignRequest, 0, len(indexes))
+	priority := types.PriorityDefault
+	for _, i := range indexes {
+		batch = append(batch, reqs[i])
+		priority = max(priority, signPriority(reqs[i].Meta))
+	}
+	fail := func(err error) {
+		for _, i := range indexes {
+			results[i].Err = err.Error()
+		}
+	}
+
+	signOneByOne := func() {
+		for _, i := range indexes {
+			sig, err := w.sign(ctx, channels, reqs[i])
+			if err != nil {
+				results[i].Err = err.Error()
+				continue
+			}
+			results[i].Signature = sig
+		}
+	}
+	// the legacy clients only sign one by one, and may not respond the batch at all
+	batchChannels := make([]*types.ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		if channel.Protocol() != nil && channel.Supports(types.MethodWalletSignBatch) {
+			batchChannels = append(batchChannels, channel)
+		}
+	}
+	if len(batchChannels) == 0 {
+		signOneByOne()
+		return
+	}
+
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var sigs []types.SignerSignature
+	err = w.SendRequestWithPriority(ctx, batchChannels, types.MethodWalletSignBatch, payload, priority, &sigs)
+	if types.IsUnsupportedMethod(err) {
+		signOneByOne()
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	if len(sigs) != len(indexes) {
+		fail(fmt.Errorf("expect %d signatures but got %d", len(indexes), len(sigs)))
+		return
+	}
+	for j, i := range indexes {
+		results[i].Signature, results[i].Err = sigs[j].Signature, sigs[j].Err
+	}
+}
+
+// WalletSignMultisig signs msg by each of signers through the wallets holding them, eg. the signers of a multisig actor
+// living in different wallets, the failed signers are reported in the results, the error is returned if all failed
+func (w *WalletEventStream) WalletSignMultisig(ctx context.Context, accounts []string, signers []address.Address, msg *sharedTypes.Message) ([]types.SignerSignature, error) {
+	return SignMultisig(ctx, signers, msg, func(ctx context.Context, signer address.Address, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error) {
+		return w.WalletSign(ctx, signer, accounts, toSign, meta)
+	})
+}
+
+// SignMultisig signs msg by each of signers with sign concurrently
+func SignMultisig(ctx context.Context, signers []address.Address, msg *sharedTypes.Message,
+	sign func(ctx context.Context, signer address.Address, toSign []byte, meta sharedTypes.MsgMeta) (*crypto.Signature, error),
+) ([]types.SignerSignature, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signers")
+	}
+	for i, signer := range signers {
+		if slices.Contains(signers[:i], signer) {
+			return nil, fmt.Errorf("duplicate signer %s", signer)
+		}
+	}
+	mb, err := msg.ToStorageBlock()
+	if err != nil {
+		return nil, fmt.Errorf("serializing message: %w", err)
+	}
+	meta := sharedTypes.MsgMeta{Type: sharedTypes.MTChainMsg, Extra: mb.RawData()}
+
+	results := make([]types.SignerSignature, len(signers))
+	var wg sync.WaitGroup
+	for i, signer := range signers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Signer = signer
+			sb, err := msg.SigningBytes(sharedTypes.AddressProtocol2SignType(signer.Protocol()))
+			if err == nil {
+				results[i].Signature, err = sign(ctx, signer, sb, meta)
+			}
+			if err != nil {
+				results[i].Err = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var errs []string
+	for _, result := range results {
+		if len(result.Err) > 0 {
+			errs = append(errs, fmt.Sprintf("%s: %s", result.Signer, result.Err))
+		}
+	}
+	if len(errs) == len(results) {
+		return nil, fmt.Errorf("all signers failed: %s", strings.Join(errs, "; "))
+	}
+	if len(errs) > 0 {
+		log.Warnf("sign message %s failed by %d of %d signers: %s", msg.Cid(), len(errs), len(results), strings.Join(errs, "; "))
+	}
+	return results, nil
+}
+
 func (w *WalletEventStream) ListWalletInfo(ctx context.Context) ([]*sharedGatewayTypes.WalletDetail, error) {
 	return w.walletConnMgr.listWalletInfo(ctx)
 }
 
+// ListChannelStates returns the states of all the wallet connections
+func (w *WalletEventStream) ListChannelStates(ctx context.Context) ([]*types.ChannelState, error) {
+	return w.walletConnMgr.listChannelStates(), nil
+}
+
 func (w *WalletEventStream) ListWalletInfoByWallet(ctx context.Context, wallet string) (*sharedGatewayTypes.WalletDetail, error) {
 	return w.walletConnMgr.listWalletInfoByWallet(ctx, wallet)
 }
 
+// DisconnectWallet closes all the connections of wallet account
+func (w *WalletEventStream) DisconnectWallet(ctx context.Context, walletAccount string) error {
+	if !w.Has(walletAccount) {
+		return fmt.Errorf("wallet %s not exit", walletAccount)
+	}
+	return w.DisconnectKey(walletAccount)
+}
+
 func (w *WalletEventStream) getValidatedAddress(ctx context.Context, channel *types.ChannelInfo, signBytes []byte, walletAccount string) ([]address.Address, error) {
 	var addrs []address.Address
 
@@ -340,6 +631,18 @@ func (w *WalletEventStream) getValidatedAddress(ctx context.Context, channel *ty
 	return validAddrs, nil
 }
 
+// signPriority lets block and election signing of miners go before message signing and the others
+func signPriority(meta sharedTypes.MsgMeta) types.Priority {
+	switch meta.Type {
+	case sharedTypes.MTBlock, sharedTypes.MTDrawRandomParam:
+		return types.PriorityWinningPoSt
+	case sharedTypes.MTChainMsg:
+		return types.PriorityMessageSign
+	default:
+		return types.PriorityDefault
+	}
+}
+
 func (w *WalletEventStream) verifyAddress(ctx context.Context, addr address.Address, channel *types.ChannelInfo, signBytes []byte, walletAccount string) error {
 	signData := GetSignData(w.randBytes, signBytes)
 	payload, err := json.Marshal(&sharedGatewayTypes.WalletSignRequest{
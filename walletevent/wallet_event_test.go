@@ -7,14 +7,19 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
 	logging "github.com/ipfs/go-log/v2"
 
+	wcrypto "github.com/filecoin-project/venus/pkg/crypto"
 	_ "github.com/filecoin-project/venus/pkg/crypto/secp"
 	sharedTypes "github.com/filecoin-project/venus/venus-shared/types"
+	sharedGatewayTypes "github.com/filecoin-project/venus/venus-shared/types/gateway"
 	"github.com/ipfs-force-community/sophon-auth/auth"
 	"github.com/ipfs-force-community/sophon-auth/core"
 
@@ -299,6 +304,320 @@ func TestWalletSign(t *testing.T) {
 	}
 }
 
+func TestSignPolicy(t *testing.T) {
+	walletAccount := "walletAccount"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walletEvent := setupWalletEvent(t, walletAccount)
+	client := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+	addrs, err := client.wallet.WalletList(ctx)
+	require.NoError(t, err)
+	require.Len(t, addrs, 2)
+	allowed, denied := addrs[0], addrs[1]
+	client.walletEventClient.SetSignPolicy(SignPolicy{
+		AllowedTypes:   []sharedTypes.MsgType{sharedTypes.MTChainMsg, sharedTypes.MTUnknown},
+		AllowedSigners: []address.Address{allowed},
+		Approve: func(ctx context.Context, req *sharedGatewayTypes.WalletSignRequest) error {
+			if req.Meta.Type == sharedTypes.MTUnknown {
+				return fmt.Errorf("denied by operator")
+			}
+			return nil
+		},
+	})
+	go client.listenWalletEvent(ctx)
+	client.walletEventClient.WaitReady(ctx)
+
+	// only the allowed signer is listed to gateway
+	has, err := walletEvent.WalletHas(ctx, allowed, []string{walletAccount})
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = walletEvent.WalletHas(ctx, denied, []string{walletAccount})
+	require.NoError(t, err)
+	require.False(t, has)
+
+	_, err = walletEvent.WalletSign(ctx, allowed, []string{walletAccount}, []byte{1, 2, 3}, sharedTypes.MsgMeta{Type: sharedTypes.MTChainMsg})
+	require.NoError(t, err)
+
+	_, err = walletEvent.WalletSign(ctx, allowed, []string{walletAccount}, []byte{1, 2, 3}, sharedTypes.MsgMeta{Type: sharedTypes.MTBlock})
+	rejected, ok := types.ParseSignRejected(err)
+	require.True(t, ok)
+	require.Equal(t, &types.SignRejectedError{Signer: allowed, Type: sharedTypes.MTBlock, Reason: "type not allowed"}, rejected)
+
+	_, err = walletEvent.WalletSign(ctx, allowed, []string{walletAccount}, []byte{1, 2, 3}, sharedTypes.MsgMeta{Type: sharedTypes.MTUnknown})
+	rejected, ok = types.ParseSignRejected(err)
+	require.True(t, ok)
+	require.Equal(t, "denied by operator", rejected.Reason)
+
+	payload := &sharedGatewayTypes.WalletSignRequest{Signer: denied, ToSign: []byte{1, 2, 3}, Meta: sharedTypes.MsgMeta{Type: sharedTypes.MTChainMsg}}
+	_, err = client.walletEventClient.walletSign(ctx, *payload)
+	rejected, ok = types.ParseSignRejected(err)
+	require.True(t, ok)
+	require.Equal(t, "signer not allowed", rejected.Reason)
+}
+
+func TestWalletProbe(t *testing.T) {
+	walletAccount := "walletAccount"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walletEvent := setupWalletEvent(t, walletAccount)
+	client := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+	go client.listenWalletEvent(ctx)
+	client.walletEventClient.WaitReady(ctx)
+
+	// the key imported after connected
+	addr := client.newkey()
+	has, err := walletEvent.WalletHas(ctx, addr, []string{walletAccount})
+	require.NoError(t, err)
+	require.False(t, has)
+
+	walletEvent.cfg.WalletProbe = true
+	walletEvent.cfg.WalletProbeInterval = 0
+	has, err = walletEvent.WalletHas(ctx, addr, []string{walletAccount})
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = walletEvent.WalletHas(ctx, address.NewForTestGetter()(), []string{walletAccount})
+	require.NoError(t, err)
+	require.False(t, has)
+
+	addr = client.newkey()
+	_, err = walletEvent.WalletSign(ctx, addr, []string{walletAccount}, []byte{1, 2, 3}, sharedTypes.MsgMeta{Type: sharedTypes.MTUnknown})
+	require.NoError(t, err)
+
+	// probed recently
+	walletEvent.cfg.WalletProbeInterval = time.Hour
+	addr = client.newkey()
+	has, err = walletEvent.WalletHas(ctx, addr, []string{walletAccount})
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestWalletSignMultisig(t *testing.T) {
+	walletAccount := "walletAccount"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walletEvent := setupWalletEvent(t, walletAccount)
+	// the signers live in different wallets
+	var signers []address.Address
+	for i := 0; i < 2; i++ {
+		client := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+		go client.listenWalletEvent(ctx)
+		client.walletEventClient.WaitReady(ctx)
+		addrs, err := client.wallet.WalletList(ctx)
+		require.NoError(t, err)
+		// the delegated signers only sign the messages of evm
+		for _, addr := range addrs {
+			if addr.Protocol() == address.SECP256K1 {
+				signers = append(signers, addr)
+			}
+		}
+	}
+	require.Len(t, signers, 2)
+	unknown := address.NewForTestGetter()()
+
+	msg := &sharedTypes.Message{To: unknown, From: unknown, Nonce: 1, Value: abi.NewTokenAmount(10), GasFeeCap: abi.NewTokenAmount(1), GasPremium: abi.NewTokenAmount(1)}
+	results, err := walletEvent.WalletSignMultisig(ctx, []string{walletAccount}, append(signers, unknown), msg)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, signer := range signers {
+		require.Equal(t, signer, results[i].Signer)
+		require.Empty(t, results[i].Err)
+		sb, err := msg.SigningBytes(sharedTypes.AddressProtocol2SignType(signer.Protocol()))
+		require.NoError(t, err)
+		require.NoError(t, wcrypto.Verify(results[i].Signature, signer, sb))
+	}
+	require.Equal(t, unknown, results[2].Signer)
+	require.Nil(t, results[2].Signature)
+	require.NotEmpty(t, results[2].Err)
+
+	_, err = walletEvent.WalletSignMultisig(ctx, []string{walletAccount}, []address.Address{unknown}, msg)
+	require.Contains(t, err.Error(), "all signers failed")
+	_, err = walletEvent.WalletSignMultisig(ctx, []string{walletAccount}, []address.Address{signers[0], signers[0]}, msg)
+	require.Contains(t, err.Error(), "duplicate signer")
+}
+
+func TestWalletSignBatch(t *testing.T) {
+	walletAccount := "walletAccount"
+	setup := func(ctx context.Context, old bool) (*WalletEventStream, []address.Address) {
+		walletEvent := setupWalletEvent(t, walletAccount)
+		var signers []address.Address
+		for i := 0; i < 2; i++ {
+			client := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+			if old {
+				// the old clients register no WalletSignBatch handler
+				eventClient := types.NewEventClient(logging.Logger("test").With(), walletEvent.ResponseWalletEvent)
+				eventClient.Handle("WalletList", client.walletEventClient.walletList)
+				eventClient.Handle("WalletSign", types.TypedHandler(client.walletEventClient.walletSign))
+				client.walletEventClient.EventClient = eventClient
+			}
+			go client.listenWalletEvent(ctx)
+			client.walletEventClient.WaitReady(ctx)
+			addrs, err := client.wallet.WalletList(ctx)
+			require.NoError(t, err)
+			signers = append(signers, addrs...)
+		}
+		return walletEvent, signers
+	}
+	check := func(t *testing.T, walletEvent *WalletEventStream, signers []address.Address) {
+		ctx := context.Background()
+		unknown := address.NewForTestGetter()()
+		var reqs []*sharedGatewayTypes.WalletSignRequest
+		for i, signer := range append(signers, unknown) {
+			reqs = append(reqs, &sharedGatewayTypes.WalletSignRequest{Signer: signer, ToSign: []byte{byte(i)}, Meta: sharedTypes.MsgMeta{Type: sharedTypes.MTUnknown}})
+		}
+		results, err := walletEvent.WalletSignBatch(ctx, []string{walletAccount}, reqs)
+		require.NoError(t, err)
+		require.Len(t, results, len(reqs))
+		for i, signer := range signers {
+			require.Equal(t, signer, results[i].Signer)
+			require.Empty(t, results[i].Err)
+			require.NoError(t, wcrypto.Verify(results[i].Signature, signer, reqs[i].ToSign))
+		}
+		require.Contains(t, results[len(signers)].Err, "no connect found for signer")
+
+		_, err = walletEvent.WalletSignBatch(ctx, []string{walletAccount}, nil)
+		require.Error(t, err)
+	}
+
+	t.Run("sign in batches", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		walletEvent, signers := setup(ctx, false)
+		check(t, walletEvent, signers)
+	})
+
+	t.Run("fallback for old clients", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		walletEvent, signers := setup(ctx, true)
+		for _, conn := range walletEvent.walletConnMgr.listConns()[walletAccount] {
+			require.Eventually(t, func() bool { return conn.Protocol() != nil }, time.Second*5, time.Millisecond*10)
+			require.False(t, conn.Supports(types.MethodWalletSignBatch))
+		}
+		check(t, walletEvent, signers)
+		require.Equal(t, 0, walletEvent.PendingRequests())
+	})
+}
+
+func TestSendRequestLeak(t *testing.T) {
+	walletAccount := "walletAccount"
+	walletEvent := setupWalletEvent(t, walletAccount)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientCtx, clientCancel := context.WithCancel(ctx)
+	var clients []*mockClient
+	for i := 0; i < 2; i++ {
+		client := setupClient(t, clientCtx, walletAccount, []string{}, walletEvent)
+		go client.listenWalletEvent(clientCtx)
+		client.walletEventClient.WaitReady(ctx)
+		clients = append(clients, client)
+	}
+
+	var channels []*types.ChannelInfo
+	for _, conn := range walletEvent.walletConnMgr.listConns()[walletAccount] {
+		channels = append(channels, conn.ChannelInfo)
+	}
+	require.Len(t, channels, 2)
+	// the first channel fails, the request is sent to the others and the losing one is canceled
+	closedCtx, closedCancel := context.WithCancel(ctx)
+	closedCancel()
+	closed := types.NewChannelInfo(closedCtx, "127.1.1.2", make(chan *sharedGatewayTypes.RequestEvent), 1)
+	for i := 0; i < 5; i++ {
+		var addrs []address.Address
+		require.NoError(t, walletEvent.SendRequest(ctx, append([]*types.ChannelInfo{closed}, channels...), "WalletList", nil, &addrs))
+		require.Len(t, addrs, 2)
+	}
+
+	// the caller returns before response
+	addrs, err := clients[0].wallet.WalletList(ctx)
+	require.NoError(t, err)
+	callCtx, callCancel := context.WithCancel(ctx)
+	callCancel()
+	_, err = walletEvent.WalletSign(callCtx, addrs[0], []string{walletAccount}, []byte{1, 2, 3}, sharedTypes.MsgMeta{Type: sharedTypes.MTUnknown})
+	require.Error(t, err)
+
+	clientCancel()
+	require.Eventually(t, func() bool { return walletEvent.PendingRequests() == 0 }, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool { return len(walletEvent.walletConnMgr.listConns()[walletAccount]) == 0 }, time.Second*5, time.Millisecond*10)
+}
+
+func TestDisconnectWallet(t *testing.T) {
+	walletAccount := "walletAccount"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walletEvent := setupWalletEvent(t, walletAccount)
+	client := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+	go client.listenWalletEvent(ctx)
+	client.walletEventClient.WaitReady(ctx)
+	client2 := setupClient(t, ctx, walletAccount, []string{}, walletEvent)
+	go client2.listenWalletEvent(ctx)
+	client2.walletEventClient.WaitReady(ctx)
+
+	walletInfo, err := walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+	require.NoError(t, err)
+	require.Len(t, walletInfo.ConnectStates, 2)
+
+	channelID := walletInfo.ConnectStates[0].ChannelID
+	require.True(t, walletEvent.DisconnectChannel(ctx, channelID))
+	require.False(t, walletEvent.DisconnectChannel(ctx, channelID))
+	walletInfo, err = walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+	require.NoError(t, err)
+	require.Len(t, walletInfo.ConnectStates, 1)
+	require.NotEqual(t, channelID, walletInfo.ConnectStates[0].ChannelID)
+
+	require.NoError(t, walletEvent.DisconnectWallet(ctx, walletAccount))
+	_, err = walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+	require.Error(t, err)
+	require.Error(t, walletEvent.DisconnectWallet(ctx, "not_exist"))
+}
+
+func TestRevalidateWallet(t *testing.T) {
+	walletAccount := "walletAccount"
+	supportAccounts := []string{"admin", "ac1"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authClient := mocks.NewMockAuthClient()
+	walletEvent := setupWalletEventWithAuth(t, walletAccount, authClient, supportAccounts...)
+	client := setupClient(t, ctx, walletAccount, supportAccounts, walletEvent)
+	go client.listenWalletEvent(ctx)
+	client.walletEventClient.WaitReady(ctx)
+
+	// still valid
+	walletEvent.Revalidate(nil)
+	walletInfo, err := walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{walletAccount, "admin", "ac1"}, walletInfo.SupportAccounts)
+
+	// drop the support account removed from sophon-auth
+	authClient.RemoveMockUser(ctx, "ac1")
+	walletEvent.Revalidate(nil)
+	walletInfo, err = walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{walletAccount, "admin"}, walletInfo.SupportAccounts)
+	require.Len(t, walletInfo.ConnectStates, 1)
+	from := walletInfo.ConnectStates[0].Addrs[0]
+	has, err := walletEvent.WalletHas(ctx, from, []string{"admin"})
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = walletEvent.WalletHas(ctx, from, []string{"ac1"})
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// close the connections of the wallet account removed
+	authClient.RemoveMockUser(ctx, walletAccount)
+	walletEvent.Revalidate(nil)
+	require.Eventually(t, func() bool {
+		_, err := walletEvent.ListWalletInfoByWallet(ctx, walletAccount)
+		return err != nil
+	}, time.Second*10, time.Millisecond*100)
+}
+
 func setupWalletEvent(t *testing.T, walletAccount string, accounts ...string) *WalletEventStream {
 	return setupWalletEventWithAuth(t, walletAccount, mocks.NewMockAuthClient(), accounts...)
 }